@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/astota/go-logging"
+	"github.com/labstack/echo/v4"
+)
+
+// errBodyTooLarge has same message as error of http.MaxBytesReader
+var errBodyTooLarge = errors.New("http: request body too large")
+
+// BodyLimitRule defines maximum body size of requests, which match path
+// prefix and content type. Empty prefix and content type match all
+// requests.
+type BodyLimitRule struct {
+	// Path prefix of requests, example "/upload"
+	PathPrefix string `yaml:"path_prefix" json:"path_prefix"`
+	// Media type of requests, example "application/json"
+	ContentType string `yaml:"content_type" json:"content_type"`
+	// Maximum body size in bytes
+	Limit int64 `yaml:"limit" json:"limit"`
+}
+
+// matches tells if rule matches request
+func (rule BodyLimitRule) matches(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+		return false
+	}
+	if rule.ContentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && strings.EqualFold(mediaType, rule.ContentType)
+}
+
+// bodyLimit returns limit of first rule, which matches request
+func bodyLimit(rules []BodyLimitRule, r *http.Request) (int64, bool) {
+	for _, rule := range rules {
+		if rule.matches(r) {
+			return rule.Limit, true
+		}
+	}
+	return 0, false
+}
+
+// bodyLimiter limits size of request body like http.MaxBytesReader, but
+// limit can be changed by middleware before body is read. Requests with
+// too large Content-Length fail without reading body.
+type bodyLimiter struct {
+	body          io.ReadCloser
+	logger        logging.Logger
+	contentLength int64
+	limit         int64
+	read          int64
+	size          int64
+	err           error
+	// Limiter of compressed body, when body is decompressed
+	compressed *bodyLimiter
+}
+
+// newBodyLimiter creates limiter of request body
+func newBodyLimiter(r *http.Request, limit int64) *bodyLimiter {
+	return &bodyLimiter{
+		body:          r.Body,
+		logger:        logging.GetLogger(r.Context()),
+		contentLength: r.ContentLength,
+		limit:         limit,
+	}
+}
+
+func (b *bodyLimiter) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.read == 0 && b.contentLength > b.limit {
+		return 0, b.tooLarge(b.contentLength)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// One extra byte is read to notice too large body
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.body.Read(p)
+	if err != nil && b.compressed.exceeded() {
+		err = errBodyTooLarge
+	}
+	b.read += int64(n)
+	if b.read > b.limit {
+		n -= int(b.read - b.limit)
+		size := b.read
+		if b.contentLength > size {
+			size = b.contentLength
+		}
+		return n, b.tooLarge(size)
+	}
+	b.err = err
+	return n, err
+}
+
+func (b *bodyLimiter) Close() error {
+	return b.body.Close()
+}
+
+// setLimit changes limit. It must be called before body is read.
+func (b *bodyLimiter) setLimit(limit int64) {
+	b.limit = limit
+	if b.compressed != nil {
+		b.compressed.setLimit(limit)
+	}
+}
+
+// tooLarge logs limit and attempted size. Connection is closed by HTTP
+// server after response, if rest of body is too large to be discarded.
+func (b *bodyLimiter) tooLarge(size int64) error {
+	b.size = size
+	b.err = errBodyTooLarge
+	b.logger.AddFields(logging.Fields{
+		"body_limit": b.limit,
+		"body_size":  size,
+	}).Info("Request body too large")
+	return b.err
+}
+
+// exceeded tells if body was larger than limit
+func (b *bodyLimiter) exceeded() bool {
+	return b != nil && b.err == errBodyTooLarge
+}
+
+// BodyLimit limits body size of requests. It can be used with echo groups
+// and routes, and overrides limits of configuration.
+func BodyLimit(limit int64) echo.MiddlewareFunc {
+	return BodyLimits(BodyLimitRule{Limit: limit})
+}
+
+// BodyLimits limits body size of requests by rules. Limit of first
+// matching rule is used. If body is larger than limit and handler has not
+// responded, structured 413 response is returned. Without rules only 413
+// response is returned for limits of configuration.
+func BodyLimits(rules ...BodyLimitRule) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			var body *bodyLimiter
+			if store := getStore(req.Context()); store != nil {
+				body = store.body
+			}
+
+			if limit, ok := bodyLimit(rules, req); ok && req.Body != nil {
+				if body != nil {
+					body.setLimit(limit)
+				} else {
+					// Request is not initialized by InitRequest
+					body = newBodyLimiter(req, limit)
+					req.Body = body
+				}
+			}
+
+			err := next(c)
+			if body == nil || !body.exceeded() || c.Response().Committed {
+				return err
+			}
+
+			return c.JSON(http.StatusRequestEntityTooLarge, bodyTooLarge(req, body))
+		}
+	}
+}
+
+// bodyTooLarge returns response of request, which body is too large
+func bodyTooLarge(r *http.Request, body *bodyLimiter) errorResponse {
+	fctx, _ := GetDefaultContext(r.Context())
+	return errorResponse{
+		Error:     "request body too large",
+		RequestID: fctx.RequestID,
+		Limit:     body.limit,
+	}
+}
@@ -0,0 +1,150 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+	"github.com/labstack/echo/v4"
+)
+
+func TestBodyLimiter(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		limit         int64
+		read          string
+		size          int64
+	}{
+		{"below limit", "12345", 5, 10, "12345", 0},
+		{"equal to limit", "12345", -1, 5, "12345", 0},
+		{"streamed over limit", "1234567890", -1, 5, "12345", 6},
+		{"content length over limit", "1234567890", 10, 5, "", 10},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			teardownTest := setupTest(t)
+			defer teardownTest(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tst.body))
+			req.ContentLength = tst.contentLength
+			b := newBodyLimiter(req, tst.limit)
+
+			data, err := ioutil.ReadAll(b)
+			if string(data) != tst.read {
+				t.Errorf("incorrect data, expected: '%s', got: '%s'", tst.read, data)
+			}
+			if (tst.size != 0) != (err == errBodyTooLarge) || b.exceeded() != (tst.size != 0) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if b.size != tst.size {
+				t.Errorf("incorrect attempted size, expected: %d, got: %d", tst.size, b.size)
+			}
+		})
+	}
+}
+
+func TestBodyLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		size        int
+		status      int
+		limit       int64
+	}{
+		{"default limit", "/api", "text/plain", 20, http.StatusRequestEntityTooLarge, 10},
+		{"below default limit", "/api", "text/plain", 10, http.StatusOK, 0},
+		{"content type limit", "/api", "application/json; charset=UTF-8", 6, http.StatusRequestEntityTooLarge, 5},
+		{"path prefix limit", "/files/report", "text/plain", 50, http.StatusOK, 0},
+		{"over path prefix limit", "/files/report", "text/plain", 101, http.StatusRequestEntityTooLarge, 100},
+		{"route limit", "/upload/file", "text/plain", 1000, http.StatusOK, 0},
+		{"over route limit", "/upload/file", "text/plain", 1001, http.StatusRequestEntityTooLarge, 1000},
+		{"route content type limit", "/upload/file", "application/json", 21, http.StatusRequestEntityTooLarge, 20},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			teardownTest := setupTest(t)
+			defer teardownTest(t)
+
+			conf := NewConfiguration()
+			conf.MaximumBodySize = 10
+			conf.BodyLimits = []BodyLimitRule{
+				{ContentType: "application/json", Limit: 5},
+				{PathPrefix: "/files", Limit: 100},
+			}
+			s := NewServer(conf)
+			read := func(c echo.Context) error {
+				if _, err := ioutil.ReadAll(c.Request().Body); err != nil {
+					return err
+				}
+				return c.String(http.StatusOK, "")
+			}
+			s.Echo().POST("/api", read)
+			s.Echo().POST("/files/report", read)
+			s.Echo().Group("/upload", BodyLimits(
+				BodyLimitRule{ContentType: "application/json", Limit: 20},
+				BodyLimitRule{Limit: 1000},
+			)).POST("/file", read)
+
+			req := httptest.NewRequest(http.MethodPost, tst.path, strings.NewReader(strings.Repeat("a", tst.size)))
+			req.Header.Set("Content-Type", tst.contentType)
+			req.Header.Set("BMG-Request-Id", "body-limit")
+			// Streamed body is read until limit is exceeded
+			req.ContentLength = -1
+			resp := httptest.NewRecorder()
+			s.Handler().ServeHTTP(resp, req)
+
+			if resp.Code != tst.status {
+				t.Fatalf("incorrect status, expected: %d, got: %d", tst.status, resp.Code)
+			}
+			if tst.status == http.StatusOK {
+				return
+			}
+
+			var body errorResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid response: %s", resp.Body.String())
+			}
+			if body.Limit != tst.limit || body.RequestID != "body-limit" {
+				t.Errorf("incorrect response: %+v", body)
+			}
+
+			l := logging.NewLogger().(*loggertest.TestLogger)
+			if l.Fields["body_limit"] != tst.limit || l.Fields["body_size"] != tst.limit+1 {
+				t.Errorf("limit and size are not logged: %v", l.Fields)
+			}
+		})
+	}
+}
+
+func TestBodyLimitContentLength(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	e := echo.New()
+	e.POST("/test", func(c echo.Context) error {
+		return c.Bind(&map[string]interface{}{})
+	}, BodyLimit(5))
+
+	// Request without InitRequest is limited by middleware
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(`{"key": "value"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusRequestEntityTooLarge, resp.Code)
+	}
+	if l := logging.NewLogger().(*loggertest.TestLogger); l.Fields["body_size"] != int64(16) {
+		t.Errorf("content length is not logged as attempted size: %v", l.Fields["body_size"])
+	}
+}
@@ -0,0 +1,166 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/astota/go-logging"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// ClientConfiguration defines which request specific values are forwarded
+// to outbound requests.
+type ClientConfiguration struct {
+	// Header where request ID is forwarded. Default: BMG-Request-Id
+	RequestIDHeader string `yaml:"request_id_header" json:"request_id_header"`
+	// Headers where DefaultContext attributes are forwarded by attribute
+	// name. Default: organization_id in BMG-Organization-Id
+	AttributeHeaders map[string]string `yaml:"attribute_headers" json:"attribute_headers"`
+	// Header where remaining time of request is forwarded in milliseconds.
+	// Default: not forwarded
+	DeadlineHeader string `yaml:"deadline_header" json:"deadline_header"`
+	// Retries of idempotent requests
+	Retry RetryPolicy `yaml:"retry" json:"retry"`
+	// Circuit breaker of each host
+	CircuitBreaker CircuitBreakerPolicy `yaml:"circuit_breaker" json:"circuit_breaker"`
+	// Limit of concurrent requests
+	Bulkhead BulkheadPolicy `yaml:"bulkhead" json:"bulkhead"`
+}
+
+// NewClientConfiguration creates client configuration, which forwards
+// BMG headers.
+func NewClientConfiguration() ClientConfiguration {
+	return ClientConfiguration{
+		RequestIDHeader: defaultRequestIDHeader,
+		AttributeHeaders: map[string]string{
+			OrganizationIDAttribute: "BMG-Organization-Id",
+		},
+	}
+}
+
+// Transport is http.RoundTripper, which forwards DefaultContext of request
+// context as headers, creates client span and injects it to request, and
+// logs outbound requests with request logger.
+type Transport struct {
+	// Base transport, which makes requests. Default: http.DefaultTransport
+	Base http.RoundTripper
+	conf ClientConfiguration
+
+	bulkhead *bulkhead
+	mux      sync.Mutex
+	circuits map[string]*circuitBreaker
+}
+
+// NewTransport creates transport, which uses given base transport.
+// Retries, circuit breaker and bulkhead are used, if those are configured.
+func NewTransport(base http.RoundTripper, conf ClientConfiguration) *Transport {
+	t := &Transport{
+		Base: base,
+		conf: conf,
+	}
+	if conf.Bulkhead.MaxConcurrent > 0 {
+		t.bulkhead = &bulkhead{
+			slots: make(chan struct{}, conf.Bulkhead.MaxConcurrent),
+			wait:  conf.Bulkhead.MaxWait,
+		}
+	}
+	return t
+}
+
+// NewClient creates HTTP client, which uses Transport. Contexts of
+// incoming requests must be used in outbound requests, so that values are
+// forwarded.
+func NewClient(conf ClientConfiguration) *http.Client {
+	return &http.Client{
+		Transport: NewTransport(nil, conf),
+	}
+}
+
+// RoundTrip makes request using base transport. Headers already defined
+// in request are not overwritten. Span is finished, when response headers
+// are received, so it includes all retries.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// Round trippers must not modify original request
+	req = req.Clone(ctx)
+	if fctx, err := GetDefaultContext(ctx); err == nil {
+		t.forward(req, fctx)
+	}
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "http.request")
+	defer span.Finish()
+	ext.SpanKindRPCClient.Set(span)
+	ext.HTTPMethod.Set(span, req.Method)
+	ext.HTTPUrl.Set(span, redactedURL(req))
+	ext.PeerHostname.Set(span, req.URL.Hostname())
+	span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	rd := getRedactor(ctx)
+	logger := logging.GetLogger(ctx).AddFields(rd.logFields(logging.Fields{
+		"outbound_method": req.Method,
+		"outbound_url":    redactedURL(req),
+	}))
+	start := time.Now()
+
+	resp, err := t.send(base, req, logger, span)
+
+	elapsed := float64(time.Since(start).Nanoseconds()) / 1000000.0
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.SetTag("client.errors", err.Error())
+		logger.AddFields(logging.Fields{
+			"outbound_elapsed_time": elapsed,
+		}).WithError(err).Info("Outbound request failed")
+		return resp, err
+	}
+
+	ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		ext.Error.Set(span, true)
+	}
+	logger.AddFields(logging.Fields{
+		"outbound_status":       resp.StatusCode,
+		"outbound_elapsed_time": elapsed,
+	}).Info("Outbound request finished")
+	return resp, nil
+}
+
+// forward sets values of DefaultContext to request headers
+func (t *Transport) forward(req *http.Request, fctx DefaultContext) {
+	setDefault := func(header, value string) {
+		if header != "" && value != "" && req.Header.Get(header) == "" {
+			req.Header.Set(header, value)
+		}
+	}
+
+	setDefault(t.conf.RequestIDHeader, fctx.RequestID)
+	for attribute, header := range t.conf.AttributeHeaders {
+		value := fctx.Attributes[attribute]
+		if value == "" && attribute == OrganizationIDAttribute {
+			value = fctx.OrganizationID
+		}
+		setDefault(header, value)
+	}
+	if remaining := fctx.Remaining(); remaining > 0 {
+		setDefault(t.conf.DeadlineHeader, strconv.FormatInt(int64(remaining/time.Millisecond), 10))
+	}
+}
+
+// redactedURL returns URL of request without query and user information,
+// which can contain secrets.
+func redactedURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	return u.String()
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestClientForwarding(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	var received http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	conf := NewClientConfiguration()
+	conf.DeadlineHeader = "X-Request-Timeout"
+	conf.AttributeHeaders["tenant"] = "X-Tenant"
+	client := NewClient(conf)
+
+	parent := tracer.StartSpan("server")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	ctx = setDefaultContext(ctx, DefaultContext{
+		RequestID:      "request-1",
+		OrganizationID: "1000",
+		Attributes:     map[string]string{"tenant": "acme"},
+		Deadline:       time.Now().Add(10 * time.Second),
+	})
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/test?token=secret", nil)
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Tenant", "explicit")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %s", err.Error())
+	}
+	resp.Body.Close()
+	parent.Finish()
+
+	if v := received.Get("BMG-Request-Id"); v != "request-1" {
+		t.Errorf("request ID is not forwarded: '%s'", v)
+	}
+	if v := received.Get("BMG-Organization-Id"); v != "1000" {
+		t.Errorf("organization ID is not forwarded: '%s'", v)
+	}
+	if v := received.Get("X-Tenant"); v != "explicit" {
+		t.Errorf("header of request is overwritten: '%s'", v)
+	}
+	if ms, err := strconv.Atoi(received.Get("X-Request-Timeout")); err != nil || ms <= 0 || ms > 10000 {
+		t.Errorf("incorrect remaining time: '%s'", received.Get("X-Request-Timeout"))
+	}
+	if req.Header.Get("BMG-Request-Id") != "" {
+		t.Errorf("original request is modified")
+	}
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("incorrect number of spans: %d", len(spans))
+	}
+	span := spans[0]
+	if span.ParentID != parent.Context().(mocktracer.MockSpanContext).SpanID {
+		t.Errorf("client span is not child of server span")
+	}
+	if span.Tag(string(ext.SpanKind)) != ext.SpanKindRPCClientEnum {
+		t.Errorf("incorrect span kind: %v", span.Tag(string(ext.SpanKind)))
+	}
+	if status := span.Tag(string(ext.HTTPStatusCode)); status != uint16(http.StatusAccepted) {
+		t.Errorf("incorrect status tag: %v", status)
+	}
+	if v := received.Get("Mockpfx-Ids-Spanid"); v != strconv.Itoa(span.SpanContext.SpanID) {
+		t.Errorf("span is not injected: '%s'", v)
+	}
+
+	l := logging.NewLogger().(*loggertest.TestLogger)
+	if l.Fields["outbound_status"] != http.StatusAccepted || l.Fields["outbound_url"] != ts.URL+"/test" {
+		t.Errorf("outbound request is not logged: %v", l.Fields)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	errFailed := errors.New("connection failed")
+	client := &http.Client{
+		Transport: NewTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errFailed
+		}), NewClientConfiguration()),
+	}
+
+	if _, err := client.Get("http://localhost/test"); !errors.Is(err, errFailed) {
+		t.Errorf("error is not returned: %v", err)
+	}
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 || spans[0].Tag("error") != true {
+		t.Errorf("error is not tagged to span")
+	}
+	if l := logging.NewLogger().(*loggertest.TestLogger); l.Fields["error.message"] != errFailed.Error() {
+		t.Errorf("error is not logged: %v", l.Fields)
+	}
+}
+
+// roundTripperFunc allows to use function as http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"net/http"
+	"os"
 	"time"
 )
 
@@ -15,13 +17,78 @@ type Configuration struct {
 	ApplicationName string `yaml:"application" json:"application"`
 	// Maximum duration that handling of requst can take. Default : 30s
 	MaximumRequestDuration time.Duration `yaml:"max_request_duration" json:"max_request_duration"`
+	// Timeout supplied by client, which can shorten request duration
+	Deadline DeadlineConfiguration `yaml:"deadline" json:"deadline"`
+	// Enforcing of request timeouts
+	Timeout TimeoutConfiguration `yaml:"timeout" json:"timeout"`
 	// Maximum request body size. Default: 1MB
 	MaximumBodySize int64 `yaml:"max_body_size" json:"max_body_size"`
+	// Body size limits by path prefix and content type. First matching
+	// limit is used instead of MaximumBodySize.
+	BodyLimits []BodyLimitRule `yaml:"body_limits" json:"body_limits"`
+	// Decompress request bodies with gzip, deflate or zstd content
+	// encoding. Body limits are applied to compressed and decompressed
+	// size, and other encodings are rejected with 415. Default: false
+	DecompressRequests bool `yaml:"decompress_requests" json:"decompress_requests"`
 	// Log Level. Default: info
 	LogLevel string `yaml:"log_level" json:"log_level"`
 	// Shutdown grace time, time which is waited before force shutdown.
 	// Defafult 30s
 	ShutdownGraceTime time.Duration `yaml:"shutdown_grace_time" json:"shutdown_grace_time"`
+	// Drain delay, time which is waited after readiness starts failing
+	// before shutdown is started. Requests are served during delay, so
+	// that load balancers have time to remove instance. Default: 0s
+	DrainDelay time.Duration `yaml:"drain_delay" json:"drain_delay"`
+	// Default timeout of each lifecycle hook. Default: 10s
+	HookTimeout time.Duration `yaml:"hook_timeout" json:"hook_timeout"`
+	// Maximum duration to wait new process to become ready in graceful
+	// restart. Default: 1m
+	RestartTimeout time.Duration `yaml:"restart_timeout" json:"restart_timeout"`
+	// TLS settings, which are used by RunTLS
+	TLS TLSConfiguration `yaml:"tls" json:"tls"`
+	// HTTP/2 settings
+	HTTP2 HTTP2Configuration `yaml:"http2" json:"http2"`
+	// File permissions of Unix domain sockets. Default: 0660
+	UnixSocketMode os.FileMode `yaml:"unix_socket_mode" json:"unix_socket_mode"`
+	// Maximum duration for reading request headers. Default: 10s
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" json:"read_header_timeout"`
+	// Maximum duration for reading entire request. Default: no timeout
+	ReadTimeout time.Duration `yaml:"read_timeout" json:"read_timeout"`
+	// Maximum duration before timing out writes of response. Should be
+	// longer than MaximumRequestDuration. Default: no timeout
+	WriteTimeout time.Duration `yaml:"write_timeout" json:"write_timeout"`
+	// Maximum time to wait next request on keep-alive connection.
+	// Default: 2m
+	IdleTimeout time.Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	// Maximum size of request headers. Default: 1MB
+	MaxHeaderBytes int `yaml:"max_header_bytes" json:"max_header_bytes"`
+	// Maximum number of concurrent connections per listener. Connections
+	// over limit are closed immediately. Default: no limit
+	MaximumConnections int `yaml:"max_connections" json:"max_connections"`
+	// PROXY protocol settings
+	ProxyProtocol ProxyProtocolConfiguration `yaml:"proxy_protocol" json:"proxy_protocol"`
+	// Networks of trusted proxies, which forwarding headers are used to
+	// resolve client address, scheme and host. Without trusted proxies,
+	// X-Forwarded-For is used without verification. Default: none
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+	// Request headers, which are tried in order to get request ID.
+	// Default: BMG-Request-Id
+	RequestIDHeaders []string `yaml:"request_id_headers" json:"request_id_headers"`
+	// Use trace ID of W3C traceparent header as request ID, if request ID
+	// headers are not defined. Default: false
+	RequestIDFromTraceparent bool `yaml:"request_id_from_traceparent" json:"request_id_from_traceparent"`
+	// Response header, where request ID is written. Default: not written
+	RequestIDResponseHeader string `yaml:"request_id_response_header" json:"request_id_response_header"`
+	// Maximum length of received request ID. Longer IDs are replaced with
+	// generated ID. Default: 128
+	MaximumRequestIDLength int `yaml:"max_request_id_length" json:"max_request_id_length"`
+	// Mappings of request headers, query parameters and cookies to logger
+	// fields, DefaultContext attributes and span tags. Default: BMG headers
+	RequestMappings []RequestMapping `yaml:"request_mappings" json:"request_mappings"`
+	// Forwarding of request values to outbound requests, see NewClient
+	Client ClientConfiguration `yaml:"client" json:"client"`
+	// Redaction of secrets in logs
+	Redaction RedactionConfiguration `yaml:"redaction" json:"redaction"`
 }
 
 // NewConfiguration Creates new middleware configuration. Default values are
@@ -38,6 +105,30 @@ func NewConfiguration() Configuration {
 		MaximumBodySize:        1 << 20,
 		LogLevel:               "info",
 		ShutdownGraceTime:      30 * time.Second,
+		HookTimeout:            10 * time.Second,
+		RestartTimeout:         time.Minute,
+		UnixSocketMode:         0660,
+		ReadHeaderTimeout:      10 * time.Second,
+		IdleTimeout:            2 * time.Minute,
+		MaxHeaderBytes:         1 << 20,
+		ProxyProtocol: ProxyProtocolConfiguration{
+			HeaderTimeout: 5 * time.Second,
+		},
+		Timeout: TimeoutConfiguration{
+			Status:  http.StatusGatewayTimeout,
+			Message: "request timeout",
+		},
+		RequestIDHeaders:       []string{defaultRequestIDHeader},
+		MaximumRequestIDLength: defaultRequestIDLength,
+		RequestMappings:        defaultRequestMappings(),
+		Client:                 NewClientConfiguration(),
+		Redaction: RedactionConfiguration{
+			Headers: defaultRedactedHeaders(),
+		},
+		TLS: TLSConfiguration{
+			MinVersion:     "1.2",
+			ReloadInterval: time.Minute,
+		},
 	}
 }
 
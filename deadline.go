@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeadlineConfiguration defines how timeout supplied by client is used
+type DeadlineConfiguration struct {
+	// Request header, which contains timeout of client. Value is either
+	// milliseconds, example "2000", or in grpc-timeout format, example
+	// "2S" or "500m". Default: not used
+	Header string `yaml:"header" json:"header"`
+	// Minimum timeout, shorter timeouts are raised to it. Default: 0
+	MinTimeout time.Duration `yaml:"min_timeout" json:"min_timeout"`
+	// Maximum timeout, longer timeouts are lowered to it.
+	// Default: MaximumRequestDuration
+	MaxTimeout time.Duration `yaml:"max_timeout" json:"max_timeout"`
+}
+
+// Units of grpc-timeout header
+var timeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// requestTimeout returns timeout of request. Timeout of client is used,
+// if it is received, and clamped to configured limits. Invalid and zero
+// timeouts are ignored.
+func (s *Server) requestTimeout(r *http.Request) time.Duration {
+	conf := s.conf.Deadline
+	if conf.Header == "" {
+		return s.conf.MaximumRequestDuration
+	}
+	value := r.Header.Get(conf.Header)
+	if value == "" {
+		return s.conf.MaximumRequestDuration
+	}
+	timeout, err := parseTimeout(value)
+	if err != nil {
+		return s.conf.MaximumRequestDuration
+	}
+
+	max := conf.MaxTimeout
+	if max <= 0 {
+		max = s.conf.MaximumRequestDuration
+	}
+	if timeout > max {
+		timeout = max
+	}
+	if timeout < conf.MinTimeout {
+		timeout = conf.MinTimeout
+	}
+	return timeout
+}
+
+// parseTimeout parses timeout in milliseconds or in grpc-timeout format.
+// Zero timeout is invalid, because request would be expired immediately.
+func parseTimeout(value string) (time.Duration, error) {
+	if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+		if ms == 0 {
+			return 0, fmt.Errorf("invalid timeout '%s'", value)
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	// grpc-timeout has at most 8 digits and unit
+	if len(value) < 2 || len(value) > 9 {
+		return 0, fmt.Errorf("invalid timeout '%s'", value)
+	}
+	unit, ok := timeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid timeout unit '%s'", value)
+	}
+	n, err := strconv.ParseUint(value[:len(value)-1], 10, 32)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid timeout '%s'", value)
+	}
+	if unit == time.Hour && n > uint64(maxDuration/time.Hour) {
+		return maxDuration, nil
+	}
+	return time.Duration(n) * unit, nil
+}
+
+const maxDuration = time.Duration(1<<63 - 1)
@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		err      bool
+	}{
+		{"2000", 2 * time.Second, false},
+		{"0", 0, true},
+		{"0S", 0, true},
+		{"00m", 0, true},
+		{"2S", 2 * time.Second, false},
+		{"500m", 500 * time.Millisecond, false},
+		{"1M", time.Minute, false},
+		{"1H", time.Hour, false},
+		{"100u", 100 * time.Microsecond, false},
+		{"100n", 100 * time.Nanosecond, false},
+		{"99999999H", maxDuration, false},
+		{"", 0, true},
+		{"S", 0, true},
+		{"2s", 0, true},
+		{"-2S", 0, true},
+		{"123456789S", 0, true},
+		{"1.5S", 0, true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.value, func(t *testing.T) {
+			timeout, err := parseTimeout(tst.value)
+			if (err != nil) != tst.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if timeout != tst.expected {
+				t.Errorf("incorrect timeout, expected: %s, got: %s", tst.expected, timeout)
+			}
+		})
+	}
+}
+
+func TestRequestDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     DeadlineConfiguration
+		header   string
+		expected time.Duration
+	}{
+		{"header not configured", DeadlineConfiguration{}, "2000", 30 * time.Second},
+		{"header missing", DeadlineConfiguration{Header: "X-Timeout-Ms"}, "", 30 * time.Second},
+		{"milliseconds", DeadlineConfiguration{Header: "X-Timeout-Ms"}, "2000", 2 * time.Second},
+		{"grpc-timeout", DeadlineConfiguration{Header: "X-Timeout-Ms"}, "3S", 3 * time.Second},
+		{"invalid", DeadlineConfiguration{Header: "X-Timeout-Ms"}, "soon", 30 * time.Second},
+		{"zero", DeadlineConfiguration{Header: "X-Timeout-Ms"}, "0", 30 * time.Second},
+		{"zero grpc-timeout", DeadlineConfiguration{Header: "X-Timeout-Ms", MinTimeout: time.Second}, "0S", 30 * time.Second},
+		{"default maximum", DeadlineConfiguration{Header: "X-Timeout-Ms"}, "60000", 30 * time.Second},
+		{"maximum", DeadlineConfiguration{Header: "X-Timeout-Ms", MaxTimeout: 5 * time.Second}, "10S", 5 * time.Second},
+		{"minimum", DeadlineConfiguration{Header: "X-Timeout-Ms", MinTimeout: time.Second}, "10", time.Second},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			teardownTest := setupTest(t)
+			defer teardownTest(t)
+
+			conf := NewConfiguration()
+			conf.Deadline = tst.conf
+
+			var fctx DefaultContext
+			var ctxDeadline time.Time
+			h := NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fctx, _ = GetDefaultContext(r.Context())
+				ctxDeadline, _ = r.Context().Deadline()
+			}))
+
+			var opts []requestOption
+			if tst.header != "" {
+				opts = append(opts, addHeader("X-Timeout-Ms", tst.header))
+			}
+			start := time.Now()
+			h.ServeHTTP(httptest.NewRecorder(), createRequest(http.MethodGet, opts...))
+
+			if !fctx.Deadline.Equal(ctxDeadline) {
+				t.Errorf("deadline of DefaultContext differs from context: %s, %s", fctx.Deadline, ctxDeadline)
+			}
+			if d := fctx.Deadline.Sub(start); d < tst.expected || d > tst.expected+time.Second {
+				t.Errorf("incorrect timeout, expected: %s, got: %s", tst.expected, d)
+			}
+		})
+	}
+}
+
+func TestDefaultContextRemaining(t *testing.T) {
+	if r := (DefaultContext{}).Remaining(); r != 0 {
+		t.Errorf("remaining time without deadline: %s", r)
+	}
+	r := DefaultContext{Deadline: time.Now().Add(time.Minute)}.Remaining()
+	if r <= 0 || r > time.Minute {
+		t.Errorf("incorrect remaining time: %s", r)
+	}
+}
@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content encodings, which are decompressed
+const supportedEncodings = "gzip, deflate, zstd"
+
+// Window size, which zstd decoders should support according to RFC 8878
+const zstdMinWindow = 8 << 20
+
+// errUnsupportedEncoding is returned for unknown content encodings
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decompressBody replaces compressed body of request with decompressing
+// reader. Compressed body is read through given limiter, so that size of
+// compressed body is limited too. Content-Encoding and Content-Length are
+// removed, because those do not describe body anymore. Decoders are created,
+// when body is read first time, so that limit can be changed before that.
+func decompressBody(r *http.Request, compressed *bodyLimiter) error {
+	encodings := splitList(r.Header["Content-Encoding"])
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	// Unsupported encodings are rejected before body is read
+	for i, encoding := range encodings {
+		encodings[i] = strings.ToLower(encoding)
+		if !supportedEncoding(encodings[i]) {
+			return fmt.Errorf("%w '%s'", errUnsupportedEncoding, encoding)
+		}
+	}
+
+	r.Body = &decompressedBody{encodings: encodings, raw: compressed}
+	r.ContentLength = -1
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	return nil
+}
+
+// supportedEncoding tells whether encoding can be decompressed
+func supportedEncoding(encoding string) bool {
+	switch encoding {
+	case "identity", "gzip", "x-gzip", "deflate", "zstd":
+		return true
+	}
+	return false
+}
+
+// decoder returns decoder of encoding. Window of zstd decoder is limited by
+// body limit, but at least minimum window, which decoders should support.
+func decoder(encoding string, body io.ReadCloser, limit int64) (io.ReadCloser, error) {
+	switch encoding {
+	case "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		return zr, nil
+	case "deflate":
+		// deflate should be zlib format, but some clients send raw
+		// deflate data, so zlib header is checked.
+		br := bufio.NewReader(body)
+		if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("invalid deflate body: %w", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+	case "zstd":
+		maxMemory := uint64(zstdMinWindow)
+		if limit > zstdMinWindow {
+			maxMemory = uint64(limit)
+		}
+		zr, err := zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxMemory(maxMemory))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zstd body: %w", err)
+		}
+		return zstdBody{zr}, nil
+	default:
+		return nil, fmt.Errorf("%w '%s'", errUnsupportedEncoding, encoding)
+	}
+}
+
+// zstdBody releases resources of zstd decoder on close
+type zstdBody struct {
+	*zstd.Decoder
+}
+
+func (b zstdBody) Close() error {
+	b.Decoder.Close()
+	return nil
+}
+
+// decompressedBody decodes compressed body. Decoders are closed with body,
+// and body cannot be read after that.
+type decompressedBody struct {
+	encodings []string
+	raw       *bodyLimiter
+	body      io.Reader
+	decoders  []io.Closer
+	err       error
+	closed    bool
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	if b.body == nil && b.err == nil {
+		b.err = b.init()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.body.Read(p)
+}
+
+// init creates decoders in reverse order of encodings
+func (b *decompressedBody) init() error {
+	body := io.ReadCloser(b.raw)
+	for i := len(b.encodings) - 1; i >= 0; i-- {
+		decoded, err := decoder(b.encodings[i], body, b.raw.limit)
+		if err != nil {
+			return err
+		}
+		if decoded != body {
+			b.decoders = append(b.decoders, decoded)
+		}
+		body = decoded
+	}
+	b.body = body
+	return nil
+}
+
+func (b *decompressedBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+
+	var err error
+	for _, d := range b.decoders {
+		if derr := d.Close(); err == nil {
+			err = derr
+		}
+	}
+	if rerr := b.raw.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
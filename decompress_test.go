@@ -0,0 +1,229 @@
+package rest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "zstd":
+		var err error
+		if w, err = zstd.NewWriter(&buf); err != nil {
+			t.Fatalf("cannot create zstd writer: %s", err.Error())
+		}
+	default:
+		t.Fatalf("unknown encoding: %s", encoding)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressBody(t *testing.T) {
+	data := []byte(`{"key": "value"}`)
+	// zstd frame, which requires 64MB window
+	largeWindow := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x80, 0x09, 0x00, 0x00, 'a'}
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		err      string
+		readErr  string
+	}{
+		{"no encoding", "", data, "", ""},
+		{"identity", "identity", data, "", ""},
+		{"gzip", "gzip", compress(t, "gzip", data), "", ""},
+		{"x-gzip", "X-Gzip", compress(t, "gzip", data), "", ""},
+		{"deflate", "deflate", compress(t, "deflate", data), "", ""},
+		{"raw deflate", "deflate", compress(t, "raw-deflate", data), "", ""},
+		{"zstd", "zstd", compress(t, "zstd", data), "", ""},
+		{"multiple encodings", "deflate, gzip", compress(t, "gzip", compress(t, "deflate", data)), "", ""},
+		{"unsupported", "br", data, "unsupported content encoding 'br'", ""},
+		{"unsupported after supported", "gzip, br", data, "unsupported content encoding 'br'", ""},
+		{"invalid gzip", "gzip", data, "", "invalid gzip body: gzip: invalid header"},
+		{"zstd window over limit", "zstd", largeWindow, "", "window size exceeded"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(tst.body))
+			if tst.encoding != "" {
+				req.Header.Set("Content-Encoding", tst.encoding)
+			}
+			req.Header.Set("Content-Length", "100")
+
+			err := decompressBody(req, newBodyLimiter(req, 1<<20))
+			if tst.err != "" {
+				if err == nil || err.Error() != tst.err {
+					t.Fatalf("incorrect error, expected: '%s', got: %v", tst.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			body, err := ioutil.ReadAll(req.Body)
+			if tst.readErr != "" {
+				if err == nil || err.Error() != tst.readErr {
+					t.Fatalf("incorrect read error, expected: '%s', got: %v", tst.readErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cannot read body: %s", err.Error())
+			}
+			if !bytes.Equal(body, data) {
+				t.Errorf("incorrect body, expected: '%s', got: '%s'", data, body)
+			}
+			if tst.encoding == "" {
+				return
+			}
+			if req.Header.Get("Content-Encoding") != "" || req.Header.Get("Content-Length") != "" || req.ContentLength != -1 {
+				t.Errorf("encoding headers are not removed: %v, %d", req.Header, req.ContentLength)
+			}
+			if err := req.Body.Close(); err != nil {
+				t.Errorf("cannot close body: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestDecompressRequests(t *testing.T) {
+	small := []byte(strings.Repeat("a", 10))
+	// Decompression bomb is small, but it is expanded over body limit
+	bomb := compress(t, "gzip", []byte(strings.Repeat("a", 1<<20)))
+	// Empty flush blocks are large, but those are decompressed to nothing
+	var flushes bytes.Buffer
+	zw := gzip.NewWriter(&flushes)
+	for flushes.Len() < 10000 {
+		zw.Flush()
+	}
+	zw.Close()
+
+	tests := []struct {
+		name       string
+		decompress bool
+		encoding   string
+		body       []byte
+		status     int
+		response   string
+	}{
+		{"decompressed", true, "gzip", compress(t, "gzip", small), http.StatusOK, string(small)},
+		{"decompression bomb", true, "gzip", bomb, http.StatusRequestEntityTooLarge, ""},
+		{"compressed body over limit", true, "gzip", flushes.Bytes(), http.StatusRequestEntityTooLarge, ""},
+		{"unsupported encoding", true, "br", small, http.StatusUnsupportedMediaType, ""},
+		{"disabled", false, "br", small, http.StatusOK, string(small)},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			teardownTest := setupTest(t)
+			defer teardownTest(t)
+
+			conf := NewConfiguration()
+			conf.MaximumBodySize = 1000
+			conf.DecompressRequests = tst.decompress
+			s := NewServer(conf)
+			s.Echo().POST("/test", func(c echo.Context) error {
+				body, err := ioutil.ReadAll(c.Request().Body)
+				if err != nil {
+					return err
+				}
+				return c.String(http.StatusOK, string(body))
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(tst.body))
+			req.Header.Set("Content-Encoding", tst.encoding)
+			req.Header.Set("BMG-Request-Id", "decompress")
+			// Streamed body is read until limit is exceeded
+			req.ContentLength = -1
+			resp := httptest.NewRecorder()
+			s.Handler().ServeHTTP(resp, req)
+
+			if resp.Code != tst.status {
+				t.Fatalf("incorrect status, expected: %d, got: %d", tst.status, resp.Code)
+			}
+			if tst.status == http.StatusOK {
+				if resp.Body.String() != tst.response {
+					t.Errorf("incorrect response, expected: '%s', got: '%s'", tst.response, resp.Body.String())
+				}
+				return
+			}
+
+			var body errorResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid response: %s", resp.Body.String())
+			}
+			if body.RequestID != "decompress" {
+				t.Errorf("incorrect response: %+v", body)
+			}
+			if tst.status == http.StatusUnsupportedMediaType && resp.Header().Get("Accept-Encoding") != supportedEncodings {
+				t.Errorf("supported encodings are not returned: %v", resp.Header())
+			}
+		})
+	}
+}
+
+func TestDecompressRequestsTimeout(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	data := []byte(strings.Repeat("a", 100))
+	conf := NewConfiguration()
+	conf.DecompressRequests = true
+	conf.MaximumRequestDuration = 50 * time.Millisecond
+	conf.Timeout.Enforce = true
+
+	type result struct {
+		body []byte
+		err  error
+	}
+	read := make(chan result, 1)
+	h := NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Body is read after timeout response
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		body, err := ioutil.ReadAll(r.Body)
+		read <- result{body, err}
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(compress(t, "zstd", data)))
+	req.Header.Set("Content-Encoding", "zstd")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusGatewayTimeout {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusGatewayTimeout, resp.Code)
+	}
+	res := <-read
+	if res.err != nil {
+		t.Fatalf("cannot read body after timeout: %s", res.err.Error())
+	}
+	if !bytes.Equal(res.body, data) {
+		t.Errorf("incorrect body, expected: '%s', got: '%s'", data, res.body)
+	}
+}
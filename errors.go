@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Errors contains multiple errors. It is returned, when several
+// independent operations can fail, example when multiple listeners
+// are shutdown.
+type Errors []error
+
+// Error returns all error messages separated by semicolon
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// errorOrNil returns nil if there is no errors, single error if there is
+// only one error and otherwise all errors.
+func (e Errors) errorOrNil() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
+
+// errorResponse is JSON body of error responses written by this package
+type errorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id"`
+	Limit     int64  `json:"limit,omitempty"`
+}
+
+// writeError writes JSON error response
+func writeError(w http.ResponseWriter, status int, body errorResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
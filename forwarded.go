@@ -0,0 +1,220 @@
+package rest
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// forwardedHop is one hop of forwarding chain. Protocol and host are
+// those, which proxy received from previous hop.
+type forwardedHop struct {
+	node  string
+	proto string
+	host  string
+}
+
+// clientInfo contains resolved client of request
+type clientInfo struct {
+	ip           net.IP
+	scheme       string
+	host         string
+	forwardedFor string
+}
+
+// ipString returns IP address of client or empty string, if it is not
+// known.
+func (c clientInfo) ipString() string {
+	if c.ip == nil {
+		return ""
+	}
+	return c.ip.String()
+}
+
+// forwardedHops returns forwarding chain of request from RFC 7239
+// Forwarded header or X-Forwarded-* headers, if Forwarded is not defined.
+func forwardedHops(h http.Header) []forwardedHop {
+	if values := h["Forwarded"]; len(values) > 0 {
+		return parseForwarded(values)
+	}
+
+	nodes := splitList(h["X-Forwarded-For"])
+	protos := splitList(h["X-Forwarded-Proto"])
+	hosts := splitList(h["X-Forwarded-Host"])
+	hops := make([]forwardedHop, len(nodes))
+	for i, node := range nodes {
+		hops[i] = forwardedHop{
+			node:  node,
+			proto: alignedValue(protos, i, len(nodes)),
+			host:  alignedValue(hosts, i, len(nodes)),
+		}
+	}
+	return hops
+}
+
+// alignedValue returns value of hop i, if there is value for each hop.
+// Otherwise last value is used, which is set by nearest proxy.
+func alignedValue(values []string, i, hops int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if len(values) == hops {
+		return values[i]
+	}
+	return values[len(values)-1]
+}
+
+// splitList splits comma separated header values
+func splitList(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// parseForwarded parses elements of RFC 7239 Forwarded header. Elements
+// without for parameter have empty node.
+func parseForwarded(values []string) []forwardedHop {
+	var hops []forwardedHop
+	for _, v := range values {
+		for _, element := range splitQuoted(v, ',') {
+			var hop forwardedHop
+			for _, pair := range splitQuoted(element, ';') {
+				i := strings.IndexByte(pair, '=')
+				if i < 0 {
+					continue
+				}
+				key := strings.ToLower(strings.TrimSpace(pair[:i]))
+				value := strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+				switch key {
+				case "for":
+					hop.node = value
+				case "proto":
+					hop.proto = strings.ToLower(value)
+				case "host":
+					hop.host = value
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// splitQuoted splits s by sep, which are not inside quoted strings
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	var quoted bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == sep && !quoted:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// nodeIP returns IP address of node. Port is optional and IPv6 addresses
+// can be in brackets. Obfuscated and unknown nodes return nil.
+func nodeIP(node string) net.IP {
+	if ip := net.ParseIP(node); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(node, "["), "]"))
+}
+
+// requestClient returns client of request. Without trusted proxies
+// forwarding headers are used like before for compatibility, but scheme
+// and host are not taken from headers.
+func requestClient(r *http.Request, trusted []*net.IPNet) clientInfo {
+	if len(trusted) > 0 {
+		return resolveClient(r, trusted)
+	}
+
+	client := clientInfo{
+		ip:           net.ParseIP(userIP(r)),
+		scheme:       "http",
+		host:         r.Host,
+		forwardedFor: r.Header.Get("X-Forwarded-For"),
+	}
+	if r.TLS != nil {
+		client.scheme = "https"
+	}
+	// Behind TCP load balancers client address is received in PROXY
+	// protocol header instead of X-Forwarded-For
+	if ip, ok := proxiedIP(r); ok && client.forwardedFor == "" {
+		client.forwardedFor = ip
+	}
+	return client
+}
+
+// resolveClient resolves client of request by walking forwarding chain
+// from nearest hop, until address is not trusted proxy. Forwarding
+// headers are used only, if peer is trusted proxy. Unix domain socket
+// peers are local, so those are trusted. Forwarded for contains verified
+// part of chain starting from client.
+func resolveClient(r *http.Request, trusted []*net.IPNet) clientInfo {
+	client := clientInfo{
+		scheme: "http",
+		host:   r.Host,
+	}
+	if r.TLS != nil {
+		client.scheme = "https"
+	}
+
+	var peer net.IP
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		peer = net.ParseIP(host)
+	}
+	client.ip = peer
+	client.forwardedFor = client.ipString()
+	if !isUnixRequest(r) && (peer == nil || !containsIP(trusted, peer)) {
+		return client
+	}
+
+	hops := forwardedHops(r.Header)
+	if len(hops) == 0 {
+		return client
+	}
+
+	i := len(hops) - 1
+	for ; i >= 0; i-- {
+		ip := nodeIP(hops[i].node)
+		client.ip = ip
+		if ip == nil || !containsIP(trusted, ip) {
+			break
+		}
+	}
+	if i < 0 {
+		// All hops are trusted, so first one is client
+		i = 0
+	}
+
+	hop := hops[i]
+	if hop.proto != "" {
+		client.scheme = hop.proto
+	}
+	if hop.host != "" {
+		client.host = hop.host
+	}
+	nodes := make([]string, 0, len(hops)-i)
+	for _, h := range hops[i:] {
+		nodes = append(nodes, h.node)
+	}
+	client.forwardedFor = strings.Join(nodes, ", ")
+	return client
+}
@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+)
+
+func TestParseForwarded(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []forwardedHop
+	}{
+		{"single", []string{"for=192.0.2.60;proto=http;by=203.0.113.43"}, []forwardedHop{{node: "192.0.2.60", proto: "http"}}},
+		{"multiple elements", []string{"for=192.0.2.43, for=198.51.100.17"}, []forwardedHop{{node: "192.0.2.43"}, {node: "198.51.100.17"}}},
+		{"multiple headers", []string{"for=192.0.2.43", "for=198.51.100.17"}, []forwardedHop{{node: "192.0.2.43"}, {node: "198.51.100.17"}}},
+		{"quoted ipv6", []string{`For="[2001:db8:cafe::17]:4711"`}, []forwardedHop{{node: "[2001:db8:cafe::17]:4711"}}},
+		{"quoted separators", []string{`for=192.0.2.43;host="example.com,x;y", for=198.51.100.17`}, []forwardedHop{{node: "192.0.2.43", host: "example.com,x;y"}, {node: "198.51.100.17"}}},
+		{"proto and host", []string{"for=192.0.2.43;proto=HTTPS;host=example.com"}, []forwardedHop{{node: "192.0.2.43", proto: "https", host: "example.com"}}},
+		{"without for", []string{"proto=https"}, []forwardedHop{{proto: "https"}}},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			if hops := parseForwarded(tst.values); !reflect.DeepEqual(hops, tst.expected) {
+				t.Errorf("incorrect hops, expected: %+v, got: %+v", tst.expected, hops)
+			}
+		})
+	}
+}
+
+func TestNodeIP(t *testing.T) {
+	tests := []struct {
+		node     string
+		expected string
+	}{
+		{"192.0.2.43", "192.0.2.43"},
+		{"192.0.2.43:4711", "192.0.2.43"},
+		{"[2001:db8:cafe::17]:4711", "2001:db8:cafe::17"},
+		{"[2001:db8:cafe::17]", "2001:db8:cafe::17"},
+		{"2001:db8:cafe::17", "2001:db8:cafe::17"},
+		{"unknown", "<nil>"},
+		{"_hidden", "<nil>"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.node, func(t *testing.T) {
+			if ip := nodeIP(tst.node); ip.String() != tst.expected {
+				t.Errorf("incorrect IP, expected: %s, got: %s", tst.expected, ip)
+			}
+		})
+	}
+}
+
+func TestResolveClient(t *testing.T) {
+	trusted, _ := parseCIDRs([]string{"10.0.0.0/8", "2001:db8::/32"})
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		tls          bool
+		headers      map[string]string
+		ip           string
+		scheme       string
+		host         string
+		forwardedFor string
+	}{
+		{"untrusted peer", "203.0.113.1:1000", false, map[string]string{"X-Forwarded-For": "6.6.6.6", "X-Forwarded-Proto": "https"},
+			"203.0.113.1", "http", "example.com", "203.0.113.1"},
+		{"untrusted peer with tls", "203.0.113.1:1000", true, nil, "203.0.113.1", "https", "example.com", "203.0.113.1"},
+		{"trusted peer without headers", "10.0.0.1:1000", false, nil, "10.0.0.1", "http", "example.com", "10.0.0.1"},
+		{"x-forwarded-for", "10.0.0.1:1000", false, map[string]string{"X-Forwarded-For": "198.51.100.17, 10.0.0.2"},
+			"198.51.100.17", "http", "example.com", "198.51.100.17, 10.0.0.2"},
+		{"spoofed x-forwarded-for", "10.0.0.1:1000", false, map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.17, 10.0.0.2"},
+			"198.51.100.17", "http", "example.com", "198.51.100.17, 10.0.0.2"},
+		{"x-forwarded-proto and host", "10.0.0.1:1000", false, map[string]string{"X-Forwarded-For": "198.51.100.17", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.example.com"},
+			"198.51.100.17", "https", "api.example.com", "198.51.100.17"},
+		{"all trusted", "10.0.0.1:1000", false, map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			"10.0.0.3", "http", "example.com", "10.0.0.3, 10.0.0.2"},
+		{"forwarded", "10.0.0.1:1000", false, map[string]string{
+			"Forwarded":       `for=6.6.6.6;proto=http, for="198.51.100.17:4711";proto=https;host=api.example.com, for=10.0.0.2`,
+			"X-Forwarded-For": "7.7.7.7",
+		}, "198.51.100.17", "https", "api.example.com", `198.51.100.17:4711, 10.0.0.2`},
+		{"forwarded ipv6", "[2001:db8::1]:1000", false, map[string]string{"Forwarded": `for="[2001:db9::17]"`},
+			"2001:db9::17", "http", "example.com", "[2001:db9::17]"},
+		{"obfuscated client", "10.0.0.1:1000", false, map[string]string{"Forwarded": "for=_hidden"},
+			"<nil>", "http", "example.com", "_hidden"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/test", nil)
+			req.RemoteAddr = tst.remoteAddr
+			if tst.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tst.headers {
+				req.Header.Set(k, v)
+			}
+
+			client := resolveClient(req, trusted)
+			if client.ip.String() != tst.ip {
+				t.Errorf("incorrect IP, expected: %s, got: %s", tst.ip, client.ip)
+			}
+			if client.scheme != tst.scheme {
+				t.Errorf("incorrect scheme, expected: %s, got: %s", tst.scheme, client.scheme)
+			}
+			if client.host != tst.host {
+				t.Errorf("incorrect host, expected: %s, got: %s", tst.host, client.host)
+			}
+			if client.forwardedFor != tst.forwardedFor {
+				t.Errorf("incorrect forwarded for, expected: '%s', got: '%s'", tst.forwardedFor, client.forwardedFor)
+			}
+		})
+	}
+}
+
+func TestInitRequestTrustedProxies(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	conf := NewConfiguration()
+	conf.TrustedProxies = []string{"10.0.0.0/8"}
+
+	var fctx DefaultContext
+	var logger logging.Logger
+	h := NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fctx, _ = GetDefaultContext(r.Context())
+		logger = logging.GetLogger(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), createRequest(http.MethodGet,
+		addHeader("Forwarded", "for=198.51.100.17;proto=https;host=api.example.com"),
+	))
+
+	if !fctx.ClientIP.Equal(net.ParseIP("198.51.100.17")) || fctx.Scheme != "https" || fctx.Host != "api.example.com" {
+		t.Errorf("incorrect client in DefaultContext: %s, %s, %s", fctx.ClientIP, fctx.Scheme, fctx.Host)
+	}
+	if fctx.ForwardedFor != "198.51.100.17" {
+		t.Errorf("incorrect ForwardedFor: '%s'", fctx.ForwardedFor)
+	}
+	if ip := logger.(*loggertest.TestLogger).Fields["user_ip"]; ip != "198.51.100.17" {
+		t.Errorf("incorrect user_ip: %v", ip)
+	}
+}
+
+func TestInitRequestInvalidTrustedProxies(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("invalid trusted proxies do not panic")
+		}
+	}()
+
+	conf := NewConfiguration()
+	conf.TrustedProxies = []string{"10.0.0.1"}
+	NewServer(conf).InitRequest(http.NotFoundHandler())
+}
+
+func TestRunInvalidTrustedProxies(t *testing.T) {
+	conf := NewConfiguration()
+	conf.TrustedProxies = []string{"not-a-cidr"}
+	s := NewServer(conf, WithAddress(freeAddress(t)))
+	if err := s.RunContext(context.Background()); err == nil {
+		t.Errorf("error is not returned for invalid trusted proxies")
+	}
+}
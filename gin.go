@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GinMiddleware returns InitRequest as gin middleware. Configuration set
+// by SetConfiguration is used.
+func GinMiddleware() gin.HandlerFunc {
+	return newServer(getConfiguration()).GinMiddleware()
+}
+
+// GinMiddleware returns request initialization of server as gin middleware.
+// It should be first middleware, so that other handlers have request ID and
+// logger. Structured 413 response is returned, when body limit is exceeded.
+// Gin context cannot be used after handler returns, so timeouts are not
+// enforced, but request context still has deadline. It panics like
+// InitRequest.
+func (s *Server) GinMiddleware() gin.HandlerFunc {
+	m := s.initRequest(false)
+	return func(c *gin.Context) {
+		called := false
+		m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			c.Request = r
+			c.Next()
+
+			if store := getStore(r.Context()); store != nil && store.body != nil &&
+				store.body.exceeded() && !c.Writer.Written() {
+				c.JSON(http.StatusRequestEntityTooLarge, bodyTooLarge(r, store.body))
+			}
+		})).ServeHTTP(c.Writer, c.Request)
+
+		// Request was rejected
+		if !called {
+			c.Abort()
+		}
+	}
+}
@@ -8,6 +8,7 @@ require (
 	github.com/foodiefm/opentracing v0.3.0
 	github.com/gin-gonic/gin v1.4.0
 	github.com/google/uuid v1.1.1
+	github.com/klauspost/compress v1.11.13
 	github.com/labstack/echo/v4 v4.1.8
 	github.com/labstack/gommon v0.2.9
 	github.com/mattn/go-colorable v0.1.2
@@ -0,0 +1,72 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/astota/go-logging"
+	"github.com/labstack/echo/v4"
+)
+
+// Ready tells if server is ready to receive traffic. Server is ready after
+// listeners are started and until draining is started.
+func (s *Server) Ready() bool {
+	s.stateMux.Lock()
+	defer s.stateMux.Unlock()
+	return s.ready && s.drainStart.IsZero()
+}
+
+// setReady changes readiness of server. When server becomes ready,
+// previous draining state is cleared.
+func (s *Server) setReady(ready bool) {
+	s.stateMux.Lock()
+	s.ready = ready
+	if ready {
+		s.drainStart = time.Time{}
+	}
+	s.stateMux.Unlock()
+}
+
+// startDrain makes readiness fail and returns time when draining was
+// started. If draining is already started, original start time is returned.
+func (s *Server) startDrain() time.Time {
+	s.stateMux.Lock()
+	defer s.stateMux.Unlock()
+	if s.drainStart.IsZero() {
+		s.drainStart = time.Now()
+		if s.conf.DrainDelay <= 0 {
+			return s.drainStart
+		}
+		logging.NewLogger().Infof("Draining server, readiness is failing for %s before shutdown", s.conf.DrainDelay)
+	}
+	return s.drainStart
+}
+
+// drainRemaining returns time which is still waited before shutdown.
+func (s *Server) drainRemaining() time.Duration {
+	return s.conf.DrainDelay - time.Since(s.startDrain())
+}
+
+// ReadinessHandler responds 200, when server is ready to receive traffic
+// and 503 when server is starting or draining. It should be used as
+// Kubernetes readiness probe.
+func (s *Server) ReadinessHandler(c echo.Context) error {
+	if !s.Ready() {
+		return c.String(http.StatusServiceUnavailable, "not ready")
+	}
+	return c.String(http.StatusOK, "ok")
+}
+
+// PreStopHandler starts draining and responds after DrainDelay has passed.
+// It can be used as Kubernetes preStop hook, so that endpoints are removed
+// before SIGTERM is sent. Drain delay is not waited again after SIGTERM.
+func (s *Server) PreStopHandler(c echo.Context) error {
+	t := time.NewTimer(s.drainRemaining())
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-c.Request().Context().Done():
+	}
+	return c.String(http.StatusOK, "")
+}
@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+	"github.com/labstack/echo/v4"
+)
+
+func TestServerDrain(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	addr := freeAddress(t)
+	conf := NewConfiguration()
+	conf.DrainDelay = 300 * time.Millisecond
+	conf.ShutdownGraceTime = time.Second
+	s := NewServer(conf, WithAddress(addr))
+	s.Echo().GET("/ready", s.ReadinessHandler)
+	s.Echo().GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, "")
+	})
+
+	if resp := callReadiness(s); resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("server is ready before start, status: %d", resp.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+
+	if resp, err := getWithRetry("http://" + addr + "/ready"); err != nil {
+		t.Fatalf("request failed: %s", err.Error())
+	} else if resp.StatusCode != http.StatusOK {
+		t.Errorf("server is not ready after start, status: %d", resp.StatusCode)
+	}
+
+	start := time.Now()
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	// Readiness fails, but requests are still served
+	if resp, err := testClient.Get("http://" + addr + "/ready"); err != nil {
+		t.Errorf("request failed while draining: %s", err.Error())
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("readiness does not fail while draining, status: %d", resp.StatusCode)
+		}
+	}
+	if resp, err := testClient.Get("http://" + addr + "/test"); err != nil {
+		t.Errorf("request failed while draining: %s", err.Error())
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("incorrect status while draining: %d", resp.StatusCode)
+		}
+	}
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+		if d := time.Since(start); d < conf.DrainDelay {
+			t.Errorf("server stopped before drain delay: %s", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("server is not stopped")
+	}
+}
+
+func TestServerPreStop(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	conf := NewConfiguration()
+	conf.DrainDelay = 100 * time.Millisecond
+	s := NewServer(conf)
+	s.setReady(true)
+
+	start := time.Now()
+	resp := httptest.NewRecorder()
+	c := s.Echo().NewContext(httptest.NewRequest(http.MethodGet, "/prestop", nil), resp)
+	if err := s.PreStopHandler(c); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if d := time.Since(start); d < conf.DrainDelay {
+		t.Errorf("pre-stop returned before drain delay: %s", d)
+	}
+	if s.Ready() {
+		t.Errorf("server is ready after pre-stop")
+	}
+
+	// Drain delay is not waited again after pre-stop
+	if d := s.drainRemaining(); d > 0 {
+		t.Errorf("drain delay remaining after pre-stop: %s", d)
+	}
+}
+
+func TestServerNoDrainDelay(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	conf := NewConfiguration()
+	conf.DrainDelay = 0
+	s := NewServer(conf, WithAddress(freeAddress(t)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+	for !s.Ready() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	if out := logging.NewLogger().(*loggertest.TestLogger).TestOutput; strings.Contains(out, "Draining") {
+		t.Errorf("draining is logged without drain delay: %s", out)
+	}
+}
+
+func callReadiness(s *Server) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	c := s.Echo().NewContext(httptest.NewRequest(http.MethodGet, "/ready", nil), resp)
+	s.ReadinessHandler(c)
+	return resp
+}
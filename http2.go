@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTP2Configuration contains HTTP/2 settings of server
+type HTTP2Configuration struct {
+	// Serve HTTP/2 without TLS (h2c) on non-TLS listeners. Default: false
+	Cleartext bool `yaml:"h2c" json:"h2c"`
+	// Maximum number of concurrent streams per connection. Default: 250
+	MaxConcurrentStreams uint32 `yaml:"max_concurrent_streams" json:"max_concurrent_streams"`
+	// Maximum frame size, which is read. Default: 1MB
+	MaxReadFrameSize uint32 `yaml:"max_read_frame_size" json:"max_read_frame_size"`
+	// Time after idle connection is closed. Default: no timeout
+	IdleTimeout time.Duration `yaml:"idle_timeout" json:"idle_timeout"`
+}
+
+// configured tells if HTTP/2 settings differ from defaults
+func (c HTTP2Configuration) configured() bool {
+	return c.Cleartext || c.MaxConcurrentStreams > 0 || c.MaxReadFrameSize > 0 || c.IdleTimeout > 0
+}
+
+// configureHTTP2 applies HTTP/2 settings to listener. TLS listeners
+// negotiate HTTP/2 using ALPN and other listeners use h2c, when it is
+// enabled.
+func (s *Server) configureHTTP2(l *listener) error {
+	c := s.conf.HTTP2
+	if !c.configured() {
+		return nil
+	}
+
+	h2 := &http2.Server{
+		MaxConcurrentStreams: c.MaxConcurrentStreams,
+		MaxReadFrameSize:     c.MaxReadFrameSize,
+		IdleTimeout:          c.IdleTimeout,
+	}
+
+	if !l.tls && !c.Cleartext {
+		return nil
+	}
+	// GOAWAY is sent on shutdown and idle timeout of server is used also
+	// for h2c connections.
+	if err := http2.ConfigureServer(l.server, h2); err != nil {
+		return err
+	}
+	if !l.tls {
+		l.h2c = &activeHandlers{}
+		l.server.Handler = l.h2c.track(h2c.NewHandler(l.server.Handler, h2))
+	}
+	return nil
+}
+
+// activeHandlers tracks running handlers. h2c connections are hijacked
+// and served by handler, so HTTP server does not wait those on shutdown.
+type activeHandlers struct {
+	mux    sync.Mutex
+	active int
+	done   chan struct{}
+}
+
+// track returns handler, which is tracked while it is running
+func (a *activeHandlers) track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mux.Lock()
+		if a.active == 0 {
+			a.done = make(chan struct{})
+		}
+		a.active++
+		a.mux.Unlock()
+
+		defer func() {
+			a.mux.Lock()
+			a.active--
+			if a.active == 0 {
+				close(a.done)
+			}
+			a.mux.Unlock()
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// wait waits until there is no running handlers or context is done
+func (a *activeHandlers) wait(ctx context.Context) error {
+	a.mux.Lock()
+	if a.active == 0 {
+		a.mux.Unlock()
+		return nil
+	}
+	done := a.done
+	a.mux.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
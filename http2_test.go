@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/http2"
+)
+
+func TestServerH2C(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tests := []struct {
+		name     string
+		h2c      bool
+		expected string
+	}{
+		{"h2c disabled", false, ""},
+		{"h2c enabled", true, "HTTP/2.0"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			addr := freeAddress(t)
+			conf := NewConfiguration()
+			conf.ShutdownGraceTime = time.Second
+			conf.HTTP2.Cleartext = tst.h2c
+			conf.HTTP2.MaxConcurrentStreams = 10
+
+			var proto string
+			s := NewServer(conf, WithAddress(addr))
+			s.Echo().GET("/test", func(c echo.Context) error {
+				proto = c.Request().Proto
+				return c.String(http.StatusOK, "")
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errs := make(chan error, 1)
+			go func() {
+				errs <- s.RunContext(ctx)
+			}()
+
+			client := &http.Client{
+				Transport: &http2.Transport{
+					AllowHTTP: true,
+					DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+						return net.Dial(network, addr)
+					},
+				},
+			}
+			var err error
+			for i := 0; i < 50; i++ {
+				var resp *http.Response
+				if resp, err = client.Get("http://" + addr + "/test"); err == nil {
+					resp.Body.Close()
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if tst.h2c && err != nil {
+				t.Errorf("request failed: %s", err.Error())
+			}
+			if proto != tst.expected {
+				t.Errorf("incorrect protocol, expected: '%s', got: '%s'", tst.expected, proto)
+			}
+
+			client.Transport.(*http2.Transport).CloseIdleConnections()
+			cancel()
+			if err := <-errs; err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestServerH2CShutdown(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	addr := freeAddress(t)
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = 5 * time.Second
+	conf.HTTP2.Cleartext = true
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := NewServer(conf, WithAddress(addr))
+	s.Echo().GET("/test", func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.String(http.StatusOK, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+	for i := 0; i < 50 && !s.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+	defer client.Transport.(*http2.Transport).CloseIdleConnections()
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := client.Get("http://" + addr + "/test")
+		results <- result{resp, err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatalf("request is not started")
+	}
+	cancel()
+
+	// In-flight stream of h2c connection is waited on shutdown
+	select {
+	case err := <-errs:
+		t.Fatalf("server stopped before request was finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	res := <-results
+	if res.err != nil {
+		t.Fatalf("request failed: %s", res.err.Error())
+	}
+	body, _ := ioutil.ReadAll(res.resp.Body)
+	res.resp.Body.Close()
+	if res.resp.StatusCode != http.StatusOK || string(body) != "done" {
+		t.Errorf("incorrect response: %d '%s'", res.resp.StatusCode, body)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestConfigureHTTP2TLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    HTTP2Configuration
+		suites  []uint16
+		wantErr bool
+	}{
+		{"not configured", HTTP2Configuration{}, nil, false},
+		{"configured", HTTP2Configuration{MaxReadFrameSize: 1 << 16}, nil, false},
+		{"required cipher suite missing", HTTP2Configuration{MaxReadFrameSize: 1 << 16}, []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA}, true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			conf := NewConfiguration()
+			conf.HTTP2 = tst.conf
+			s := NewServer(conf)
+			l := &listener{
+				name:   DefaultListenerName,
+				server: &http.Server{TLSConfig: &tls.Config{CipherSuites: tst.suites}},
+				tls:    true,
+			}
+
+			err := s.configureHTTP2(l)
+			if (err != nil) != tst.wantErr {
+				t.Fatalf("unexpected error value: %v", err)
+			}
+			if err == nil && tst.conf.configured() && l.server.TLSNextProto["h2"] == nil {
+				t.Errorf("HTTP/2 is not configured")
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDGenerator generates request IDs for requests, which do not
+// have valid request ID.
+type RequestIDGenerator interface {
+	// NewID returns new request ID
+	NewID() string
+}
+
+// RequestIDGeneratorFunc allows to use function as RequestIDGenerator
+type RequestIDGeneratorFunc func() string
+
+// NewID calls f()
+func (f RequestIDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// WithRequestIDGenerator sets generator of request IDs. Default is
+// UUIDv4Generator.
+func WithRequestIDGenerator(g RequestIDGenerator) Option {
+	return func(s *Server) {
+		s.idGenerator = g
+	}
+}
+
+// UUIDv4Generator generates random UUIDs
+type UUIDv4Generator struct{}
+
+// NewID returns new UUID version 4
+func (UUIDv4Generator) NewID() string {
+	return uuid.New().String()
+}
+
+// UUIDv7Generator generates time-sortable UUIDs
+type UUIDv7Generator struct{}
+
+// NewID returns new UUID version 7, which starts with millisecond timestamp
+func (UUIDv7Generator) NewID() string {
+	var u uuid.UUID
+	randomBytes(u[6:])
+
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	u[6] = u[6]&0x0f | 0x70 // version 7
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return u.String()
+}
+
+// Crockford's base32 alphabet used by ULID
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator generates time-sortable ULIDs
+type ULIDGenerator struct{}
+
+// NewID returns new ULID, which is 26 characters long
+func (ULIDGenerator) NewID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	randomBytes(b[6:])
+
+	// 128 bits are encoded as 26 characters of 5 bits, so first
+	// character contains only 3 bits.
+	n := new(big.Int).SetBytes(b[:])
+	out := make([]byte, 26)
+	mask := big.NewInt(31)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = ulidAlphabet[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 5)
+	}
+
+	return string(out)
+}
+
+// KSUID epoch starts 13.5.2014
+const ksuidEpoch = 1400000000
+
+// Base62 alphabet used by KSUID
+const ksuidAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// KSUIDGenerator generates time-sortable KSUIDs
+type KSUIDGenerator struct{}
+
+// NewID returns new KSUID, which is 27 characters long
+func (KSUIDGenerator) NewID() string {
+	var b [20]byte
+	binary.BigEndian.PutUint32(b[0:4], uint32(time.Now().Unix()-ksuidEpoch))
+	randomBytes(b[4:])
+
+	n := new(big.Int).SetBytes(b[:])
+	out := make([]byte, 27)
+	base := big.NewInt(62)
+	mod := new(big.Int)
+	for i := len(out) - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		out[i] = ksuidAlphabet[mod.Int64()]
+	}
+
+	return string(out)
+}
+
+// PrefixedGenerator adds prefix to IDs of other generator, example
+// service name.
+type PrefixedGenerator struct {
+	Prefix    string
+	Generator RequestIDGenerator
+}
+
+// NewID returns ID of generator with prefix
+func (g PrefixedGenerator) NewID() string {
+	return g.Prefix + g.Generator.NewID()
+}
+
+// randomBytes fills b with random bytes
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("cannot read random bytes: %s", err.Error()))
+	}
+}
+
+// validRequestID tells if request ID received from client can be used.
+// Too long IDs and IDs with other than alphanumeric characters and
+// separators are rejected.
+func validRequestID(id string, maxLength int) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
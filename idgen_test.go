@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRequestIDGenerators(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator RequestIDGenerator
+		pattern   string
+	}{
+		{"uuid v4", UUIDv4Generator{}, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"uuid v7", UUIDv7Generator{}, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"ulid", ULIDGenerator{}, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+		{"ksuid", KSUIDGenerator{}, `^[0-9A-Za-z]{27}$`},
+		{"prefixed", PrefixedGenerator{Prefix: "orders-", Generator: UUIDv4Generator{}}, `^orders-[0-9a-f-]{36}$`},
+		{"func", RequestIDGeneratorFunc(func() string { return "static" }), `^static$`},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			id := tst.generator.NewID()
+			if !regexp.MustCompile(tst.pattern).MatchString(id) {
+				t.Errorf("incorrect ID format: '%s'", id)
+			}
+			if !validRequestID(id, defaultRequestIDLength) {
+				t.Errorf("generated ID is not valid: '%s'", id)
+			}
+		})
+	}
+}
+
+func TestTimeOrderedGenerators(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator RequestIDGenerator
+		interval  time.Duration
+	}{
+		{"uuid v7", UUIDv7Generator{}, 2 * time.Millisecond},
+		{"ulid", ULIDGenerator{}, 2 * time.Millisecond},
+		{"ksuid", KSUIDGenerator{}, 1100 * time.Millisecond},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			first := tst.generator.NewID()
+			time.Sleep(tst.interval)
+			second := tst.generator.NewID()
+			if first >= second {
+				t.Errorf("IDs are not ordered by time: '%s', '%s'", first, second)
+			}
+		})
+	}
+}
+
+func TestUUIDv7Timestamp(t *testing.T) {
+	u, err := uuid.Parse(UUIDv7Generator{}.NewID())
+	if err != nil {
+		t.Fatalf("cannot parse UUID: %s", err.Error())
+	}
+	if u.Version() != 7 || u.Variant() != uuid.RFC4122 {
+		t.Errorf("incorrect version or variant: %d, %s", u.Version(), u.Variant())
+	}
+
+	var ms int64
+	for _, b := range u[:6] {
+		ms = ms<<8 | int64(b)
+	}
+	if d := time.Since(time.Unix(0, ms*int64(time.Millisecond))); d < 0 || d > time.Second {
+		t.Errorf("incorrect timestamp, difference to now: %s", d)
+	}
+}
+
+func TestWithRequestIDGenerator(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	s := NewServer(NewConfiguration(), WithRequestIDGenerator(PrefixedGenerator{
+		Prefix:    "svc_",
+		Generator: ULIDGenerator{},
+	}))
+
+	var requestID string
+	h := s.InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fctx, _ := GetDefaultContext(r.Context())
+		requestID = fctx.RequestID
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), createRequest(http.MethodGet))
+
+	if !strings.HasPrefix(requestID, "svc_") || len(requestID) != 30 {
+		t.Errorf("request ID is not generated with generator: '%s'", requestID)
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string
+		valid bool
+	}{
+		{"alphanumeric", "4bf92f3577b3", true},
+		{"separators", "svc_a.b:c-d", true},
+		{"empty", "", false},
+		{"max length", strings.Repeat("a", 16), true},
+		{"too long", strings.Repeat("a", 17), false},
+		{"space", "a b", false},
+		{"newline", "a\nb", false},
+		{"non ascii", "ä", false},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			if valid := validRequestID(tst.id, 16); valid != tst.valid {
+				t.Errorf("incorrect result for '%s', expected: %t, got: %t", tst.id, tst.valid, valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/astota/go-logging"
+)
+
+// Environment variables used in systemd socket activation. Same variables
+// are used to pass listeners to new process in graceful restart.
+const (
+	listenPidEnv     = "LISTEN_PID"
+	listenFdsEnv     = "LISTEN_FDS"
+	listenFdNamesEnv = "LISTEN_FDNAMES"
+	// File descriptor, where new process reports readiness in graceful
+	// restart
+	listenReadyFdEnv = "LISTEN_READY_FD"
+)
+
+// listenFdsStart is first file descriptor passed by systemd
+var listenFdsStart = 3
+
+// WithRestartSignal enables graceful restart, when one of given signals
+// is received. If signals are not given, SIGHUP is used.
+func WithRestartSignal(sig ...os.Signal) Option {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+	return func(s *Server) {
+		s.restartSignals = append(s.restartSignals, sig...)
+	}
+}
+
+// inheritedListener is listener passed from systemd or parent process
+type inheritedListener struct {
+	name string
+	ln   net.Listener
+}
+
+// inheritListeners returns listeners passed by systemd socket activation
+// or by parent process in graceful restart, and file where readiness is
+// reported to parent. Environment variables are unset, so that those are
+// not passed to child processes.
+func inheritListeners() ([]*inheritedListener, *os.File, error) {
+	fds := os.Getenv(listenFdsEnv)
+	if fds == "" {
+		return nil, nil, nil
+	}
+	pid := os.Getenv(listenPidEnv)
+	names := strings.Split(os.Getenv(listenFdNamesEnv), ":")
+	readyFd := os.Getenv(listenReadyFdEnv)
+	os.Unsetenv(listenPidEnv)
+	os.Unsetenv(listenFdsEnv)
+	os.Unsetenv(listenFdNamesEnv)
+	os.Unsetenv(listenReadyFdEnv)
+
+	// Listeners are meant to other process. Parent process in graceful
+	// restart does not know pid of child, so pid is not required.
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil, nil
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, nil, fmt.Errorf("invalid %s value '%s'", listenFdsEnv, fds)
+	}
+
+	var ready *os.File
+	if readyFd != "" {
+		fd, err := strconv.Atoi(readyFd)
+		if err != nil || fd < 0 {
+			return nil, nil, fmt.Errorf("invalid %s value '%s'", listenReadyFdEnv, readyFd)
+		}
+		ready = os.NewFile(uintptr(fd), "ready")
+	}
+
+	var inherited []*inheritedListener
+	for i := 0; i < n; i++ {
+		name := ""
+		if i < len(names) && names[i] != "unknown" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			closeInherited(inherited)
+			if ready != nil {
+				ready.Close()
+			}
+			return nil, nil, fmt.Errorf("inherited file descriptor %d: %w", listenFdsStart+i, err)
+		}
+		inherited = append(inherited, &inheritedListener{name: name, ln: ln})
+	}
+
+	return inherited, ready, nil
+}
+
+// takeInherited returns inherited listener for listener. Listener is
+// matched by name and unnamed listeners by their order.
+func takeInherited(inherited []*inheritedListener, name string, i int) net.Listener {
+	for _, il := range inherited {
+		if il.ln != nil && il.name == name {
+			ln := il.ln
+			il.ln = nil
+			return ln
+		}
+	}
+
+	if i < len(inherited) && inherited[i].ln != nil && inherited[i].name == "" {
+		ln := inherited[i].ln
+		inherited[i].ln = nil
+		return ln
+	}
+
+	return nil
+}
+
+// closeInherited closes inherited listeners, which are not used
+func closeInherited(inherited []*inheritedListener) {
+	for _, il := range inherited {
+		if il.ln != nil {
+			il.ln.Close()
+			il.ln = nil
+		}
+	}
+}
+
+// inheritance contains listeners inherited by process. Environment is
+// parsed once and listeners are shared by all servers of process. Listeners,
+// which are not used, are closed after every running server has taken its
+// own. Readiness is reported to parent, when every running server is ready.
+type inheritance struct {
+	once      sync.Once
+	mux       sync.Mutex
+	listeners []*inheritedListener
+	ready     *os.File
+	err       error
+	// Servers, which have not taken their listeners
+	claiming int
+	// Servers, which are not ready
+	starting int
+}
+
+// inherited contains listeners of process
+var inherited = &inheritance{}
+
+// register adds server, which is waited before listeners are closed and
+// readiness is reported
+func (in *inheritance) register() {
+	in.mux.Lock()
+	in.claiming++
+	in.starting++
+	in.mux.Unlock()
+}
+
+// load parses inherited listeners on first call
+func (in *inheritance) load() error {
+	in.once.Do(func() {
+		in.listeners, in.ready, in.err = inheritListeners()
+	})
+	return in.err
+}
+
+// take returns inherited listener for listener of server
+func (in *inheritance) take(name string, i int) net.Listener {
+	in.mux.Lock()
+	defer in.mux.Unlock()
+	return takeInherited(in.listeners, name, i)
+}
+
+// claimed tells that server has taken its listeners
+func (in *inheritance) claimed() {
+	in.mux.Lock()
+	defer in.mux.Unlock()
+	in.claiming--
+	if in.claiming <= 0 {
+		closeInherited(in.listeners)
+	}
+}
+
+// started tells that server is ready
+func (in *inheritance) started() {
+	in.mux.Lock()
+	defer in.mux.Unlock()
+	in.starting--
+	if in.starting <= 0 && in.ready != nil {
+		in.ready.Write([]byte{1})
+		in.ready.Close()
+		in.ready = nil
+	}
+}
+
+// filer is implemented by listeners, which can be passed to other process
+type filer interface {
+	File() (*os.File, error)
+}
+
+// setRunning stores running listeners and function to stop server
+func (s *Server) setRunning(ls []*listener, stop context.CancelFunc) {
+	s.stateMux.Lock()
+	s.running = ls
+	s.stop = stop
+	s.stateMux.Unlock()
+}
+
+// Restart starts new process of same binary, which inherits listening
+// sockets of server. This server is then stopped through normal drain
+// and shutdown, when new process is ready. New process is killed, if it
+// is not ready within RestartTimeout.
+func (s *Server) Restart() error {
+	s.stateMux.Lock()
+	ls, stop := s.running, s.stop
+	s.stateMux.Unlock()
+	if stop == nil {
+		return fmt.Errorf("server is not running")
+	}
+
+	timeout := s.conf.RestartTimeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	if err := startChild(ls, timeout); err != nil {
+		return err
+	}
+
+	logging.NewLogger().Info("New process started, stopping server")
+	stop()
+	return nil
+}
+
+// startChild starts new process, which inherits listeners, and waits until
+// it is ready
+func startChild(ls []*listener, timeout time.Duration) error {
+	var files []*os.File
+	var names []string
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, l := range ls {
+		fl, ok := l.ln.(filer)
+		if !ok {
+			return fmt.Errorf("listener %s cannot be passed to new process", l.name)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return fmt.Errorf("listener %s: %w", l.name, err)
+		}
+		files = append(files, f)
+		names = append(names, l.name)
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "LISTEN_") {
+			env = append(env, e)
+		}
+	}
+	// Readiness is reported through pipe after listeners
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	defer w.Close()
+
+	env = append(env,
+		fmt.Sprintf("%s=%d", listenFdsEnv, len(files)),
+		fmt.Sprintf("%s=%s", listenFdNamesEnv, strings.Join(names, ":")),
+		fmt.Sprintf("%s=%d", listenReadyFdEnv, listenFdsStart+len(files)),
+	)
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("cannot start new process: %w", err)
+	}
+	// Pipe is closed, if new process exits before it is ready
+	w.Close()
+	if err := waitReady(r, timeout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("new process is not ready: %w", err)
+	}
+
+	// Socket files are used by new process, so those must not be removed
+	// when this server closes its listeners.
+	for _, l := range ls {
+		if ul, ok := l.ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	return nil
+}
+
+// waitReady waits until readiness is reported through pipe
+func waitReady(r *os.File, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		if _, err := r.Read(b); err != nil {
+			result <- fmt.Errorf("process exited during startup: %w", err)
+			return
+		}
+		result <- nil
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-t.C:
+		return fmt.Errorf("timeout after %s", timeout)
+	}
+}
+
+// handleRestartSignals restarts server, when restart signal is received
+func (s *Server) handleRestartSignals(done <-chan struct{}) {
+	if len(s.restartSignals) == 0 {
+		return
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, s.restartSignals...)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case sig := <-ch:
+				logging.NewLogger().Infof("Received signal %s, restarting server", sig)
+				if err := s.Restart(); err != nil {
+					logging.NewLogger().WithError(err).Error("Could not restart server")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,308 @@
+package rest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// passListener sets environment as listener would be passed by systemd
+func passListener(t *testing.T, ln net.Listener, names string) func() {
+	t.Helper()
+	f, err := ln.(filer).File()
+	if err != nil {
+		t.Fatalf("cannot get listener file: %s", err.Error())
+	}
+
+	start, in := listenFdsStart, inherited
+	listenFdsStart = int(f.Fd())
+	inherited = &inheritance{}
+	os.Setenv(listenFdsEnv, "1")
+	os.Setenv(listenFdNamesEnv, names)
+	os.Setenv(listenPidEnv, strconv.Itoa(os.Getpid()))
+
+	return func() {
+		listenFdsStart, inherited = start, in
+		os.Unsetenv(listenFdsEnv)
+		os.Unsetenv(listenFdNamesEnv)
+		os.Unsetenv(listenPidEnv)
+		f.Close()
+	}
+}
+
+func TestInheritListeners(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     string
+		pid       string
+		listeners int
+		takeName  string
+	}{
+		{"named listener", "admin", "", 1, "admin"},
+		{"unnamed listener", "unknown", "", 1, DefaultListenerName},
+		{"other process", "admin", "1", 0, ""},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("cannot listen: %s", err.Error())
+			}
+			defer ln.Close()
+
+			reset := passListener(t, ln, tst.names)
+			defer reset()
+			if tst.pid != "" {
+				os.Setenv(listenPidEnv, tst.pid)
+			}
+
+			inherited, ready, err := inheritListeners()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			defer closeInherited(inherited)
+			if ready != nil {
+				t.Errorf("ready file is returned without %s", listenReadyFdEnv)
+			}
+
+			if _, exists := os.LookupEnv(listenFdsEnv); exists {
+				t.Errorf("%s is not unset", listenFdsEnv)
+			}
+			if len(inherited) != tst.listeners {
+				t.Fatalf("incorrect number of listeners, expected: %d, got: %d", tst.listeners, len(inherited))
+			}
+			if tst.listeners == 0 {
+				return
+			}
+
+			if l := takeInherited(inherited, "other", 1); l != nil {
+				t.Errorf("listener is matched incorrectly")
+			}
+			l := takeInherited(inherited, tst.takeName, 0)
+			if l == nil {
+				t.Fatalf("listener is not matched")
+			}
+			if l.Addr().String() != ln.Addr().String() {
+				t.Errorf("incorrect address, expected: '%s', got: '%s'", ln.Addr(), l.Addr())
+			}
+			if l := takeInherited(inherited, tst.takeName, 0); l != nil {
+				t.Errorf("listener is returned twice")
+			}
+			l.Close()
+		})
+	}
+}
+
+func TestServerInheritedListener(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	addr := ln.Addr().String()
+	reset := passListener(t, ln, DefaultListenerName)
+	defer reset()
+	ln.Close()
+
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	// Address cannot be opened, so requests are served by inherited listener
+	s := NewServer(conf, WithAddress("invalid:address:1"))
+	s.Echo().GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, "")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+
+	if resp, err := getWithRetry("http://" + addr + "/test"); err != nil {
+		t.Errorf("request failed: %s", err.Error())
+	} else if resp.StatusCode != http.StatusOK {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestServerReadyWithMiddlewareServer(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	reset := passListener(t, ln, DefaultListenerName)
+	defer reset()
+	ln.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("cannot create pipe: %s", err.Error())
+	}
+	defer r.Close()
+	fd, err := syscall.Dup(int(w.Fd()))
+	w.Close()
+	if err != nil {
+		t.Fatalf("cannot duplicate pipe: %s", err.Error())
+	}
+	os.Setenv(listenReadyFdEnv, strconv.Itoa(fd))
+
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	// Server, which is only used as middleware, is not waited
+	e := echo.New()
+	e.Pre(NewServer(conf).EchoMiddleware())
+
+	s := NewServer(conf, WithAddress("invalid:address:1"))
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+
+	if err := waitReady(r, time.Second); err != nil {
+		t.Errorf("readiness is not reported: %s", err.Error())
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestInheritanceShared(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	defer ln.Close()
+	reset := passListener(t, ln, "admin")
+	defer reset()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("cannot create pipe: %s", err.Error())
+	}
+	defer r.Close()
+	// Ready file takes ownership of descriptor
+	fd, err := syscall.Dup(int(w.Fd()))
+	w.Close()
+	if err != nil {
+		t.Fatalf("cannot duplicate pipe: %s", err.Error())
+	}
+	os.Setenv(listenReadyFdEnv, strconv.Itoa(fd))
+
+	in := &inheritance{}
+	in.register()
+	in.register()
+
+	// First server does not use inherited listener
+	if err := in.load(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if l := in.take(DefaultListenerName, 0); l != nil {
+		t.Errorf("listener is matched incorrectly")
+	}
+	in.claimed()
+
+	// Listener is not closed before second server has taken it
+	if err := in.load(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	l := in.take("admin", 0)
+	if l == nil {
+		t.Fatalf("listener is not shared between servers")
+	}
+	defer l.Close()
+	in.claimed()
+
+	in.started()
+	if in.ready == nil {
+		t.Errorf("readiness is reported before every server is ready")
+	}
+	in.started()
+	if err := waitReady(r, time.Second); err != nil {
+		t.Errorf("readiness is not reported: %s", err.Error())
+	}
+}
+
+func TestInheritanceCloseUnclaimed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	defer ln.Close()
+	reset := passListener(t, ln, "admin")
+	defer reset()
+
+	in := &inheritance{}
+	in.register()
+	if err := in.load(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	l := in.listeners[0].ln
+	in.claimed()
+
+	if in.listeners[0].ln != nil {
+		t.Errorf("unclaimed listener is not released")
+	}
+	if _, err := l.Accept(); err == nil {
+		t.Errorf("unclaimed listener is not closed")
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(*os.File)
+		err   bool
+	}{
+		{"ready", func(w *os.File) { w.Write([]byte{1}) }, false},
+		{"exited", func(w *os.File) { w.Close() }, true},
+		{"timeout", func(w *os.File) {}, true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("cannot create pipe: %s", err.Error())
+			}
+			defer r.Close()
+			defer w.Close()
+
+			tst.write(w)
+			err = waitReady(r, 50*time.Millisecond)
+			if tst.err && err == nil {
+				t.Errorf("error is not returned")
+			}
+			if !tst.err && err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestRestartNotRunning(t *testing.T) {
+	s := NewServer(NewConfiguration())
+	if err := s.Restart(); err == nil {
+		t.Errorf("error is not returned when server is not running")
+	}
+}
@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/astota/go-logging"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// Hook is lifecycle hook of server. OnStart functions are run in
+// registration order before server becomes ready and OnStop functions are
+// run in reverse order after listeners are stopped. Either of functions
+// can be nil.
+type Hook struct {
+	// Name identifies hook in logs, traces and errors
+	Name string
+	// OnStart is run when server is started, example to warm up caches.
+	OnStart func(context.Context) error
+	// OnStop is run when server is stopped, example to close database pool.
+	OnStop func(context.Context) error
+	// Timeout of each hook function. If zero, HookTimeout from configuration
+	// is used.
+	Timeout time.Duration
+}
+
+// WithHook adds lifecycle hook to server
+func WithHook(h Hook) Option {
+	return func(s *Server) {
+		s.hooks = append(s.hooks, h)
+	}
+}
+
+// AddHook adds lifecycle hook to server. Hooks must be added before
+// server is run.
+func (s *Server) AddHook(h Hook) {
+	s.hooks = append(s.hooks, h)
+}
+
+// AddCloser adds closer, which is closed when server is stopped. Example
+// closer returned by InitGlobalTracer should be added first, so that it
+// is closed last and traces of other hooks are flushed.
+func (s *Server) AddCloser(name string, c io.Closer) {
+	s.AddHook(Hook{
+		Name: name,
+		OnStop: func(context.Context) error {
+			return c.Close()
+		},
+	})
+}
+
+// runStartHooks runs start hooks in registration order. Number of started
+// hooks is returned, so that only those are stopped. If hook fails, rest
+// of hooks are not started.
+func (s *Server) runStartHooks(ctx context.Context) (int, error) {
+	for i, h := range s.hooks {
+		if h.OnStart == nil {
+			continue
+		}
+		if err := s.runHook(ctx, "start", h, h.OnStart); err != nil {
+			return i, err
+		}
+	}
+	return len(s.hooks), nil
+}
+
+// runStopHooks runs stop hooks of started hooks in reverse order. All
+// hooks are run even if some of those fail and errors are collected.
+func (s *Server) runStopHooks(started int) Errors {
+	var result Errors
+	for i := started - 1; i >= 0; i-- {
+		h := s.hooks[i]
+		if h.OnStop == nil {
+			continue
+		}
+		if err := s.runHook(context.Background(), "stop", h, h.OnStop); err != nil {
+			result = append(result, err)
+		}
+	}
+	return result
+}
+
+// runHook runs single hook function with timeout. Hook is logged and traced.
+func (s *Server) runHook(ctx context.Context, phase string, h Hook, fn func(context.Context) error) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = s.conf.HookTimeout
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, fmt.Sprintf("lifecycle.%s", phase))
+	defer span.Finish()
+	span.SetTag("hook", h.Name)
+
+	logger := logging.NewLogger().AddFields(logging.Fields{
+		"hook":  h.Name,
+		"phase": phase,
+	})
+	ctx = logging.SetLogger(ctx, logger)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logger.Info("Running lifecycle hook")
+	t := time.Now()
+
+	// Hook is run in goroutine, so that timeout is enforced even if
+	// hook does not respect context.
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Hook may have finished at same time, when parent was cancelled
+		select {
+		case err = <-done:
+		default:
+			err = ctx.Err()
+		}
+	}
+
+	logger = logger.AddFields(logging.Fields{
+		"elapsed_time": float64(time.Since(t).Nanoseconds()) / 1000000.0,
+	})
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		logger.WithError(err).Error("Lifecycle hook failed")
+		return fmt.Errorf("%s hook %s: %w", phase, h.Name, err)
+	}
+
+	logger.Info("Lifecycle hook finished")
+	return nil
+}
@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+type testCloser struct {
+	closed *[]string
+	name   string
+}
+
+func (c testCloser) Close() error {
+	*c.closed = append(*c.closed, c.name)
+	return nil
+}
+
+func TestServerHooks(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	var calls []string
+	hook := func(name string) Hook {
+		return Hook{
+			Name: name,
+			OnStart: func(context.Context) error {
+				calls = append(calls, "start "+name)
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				calls = append(calls, "stop "+name)
+				return nil
+			},
+		}
+	}
+
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	s := NewServer(conf, WithAddress(freeAddress(t)), WithHook(hook("first")))
+	s.AddCloser("tracer", testCloser{closed: &calls, name: "tracer"})
+	s.AddHook(hook("second"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.AddHook(Hook{
+		Name: "ready",
+		OnStart: func(context.Context) error {
+			if s.Ready() {
+				t.Errorf("server is ready before start hooks are finished")
+			}
+			cancel()
+			return nil
+		},
+	})
+
+	if err := s.RunContext(ctx); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	expected := []string{"start first", "start second", "stop second", "tracer", "stop first"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("incorrect hook order, expected: %v, got: %v", expected, calls)
+	}
+
+	// Each start and stop function is traced
+	if spans := tracer.FinishedSpans(); len(spans) != 6 {
+		t.Errorf("incorrect number of spans, expected: %d, got: %d", 6, len(spans))
+	}
+}
+
+func TestServerHookErrors(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var stopped []string
+	conf := NewConfiguration()
+	conf.HookTimeout = 50 * time.Millisecond
+	s := NewServer(conf, WithAddress(freeAddress(t)))
+	s.AddHook(Hook{
+		Name: "failing stop",
+		OnStop: func(context.Context) error {
+			stopped = append(stopped, "failing stop")
+			return errors.New("stop failed")
+		},
+	})
+	s.AddHook(Hook{
+		Name: "slow start",
+		OnStart: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+	s.AddHook(Hook{
+		Name: "not started",
+		OnStop: func(context.Context) error {
+			stopped = append(stopped, "not started")
+			return nil
+		},
+	})
+
+	err := s.RunContext(context.Background())
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("incorrect error type: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("incorrect number of errors, expected: %d, got: %d", 2, len(errs))
+	}
+	if !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("start hook timeout is not reported: %s", errs[0])
+	}
+	if !reflect.DeepEqual(stopped, []string{"failing stop"}) {
+		t.Errorf("incorrect hooks stopped: %v", stopped)
+	}
+}
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/astota/go-logging"
+)
+
+// applyLimits sets timeouts and header limits of configuration to HTTP
+// server. Values already set in server are not changed.
+func (s *Server) applyLimits(hs *http.Server) {
+	if hs.ReadHeaderTimeout == 0 {
+		hs.ReadHeaderTimeout = s.conf.ReadHeaderTimeout
+	}
+	if hs.ReadTimeout == 0 {
+		hs.ReadTimeout = s.conf.ReadTimeout
+	}
+	if hs.WriteTimeout == 0 {
+		hs.WriteTimeout = s.conf.WriteTimeout
+	}
+	if hs.IdleTimeout == 0 {
+		hs.IdleTimeout = s.conf.IdleTimeout
+	}
+	if hs.MaxHeaderBytes == 0 {
+		hs.MaxHeaderBytes = s.conf.MaxHeaderBytes
+	}
+}
+
+// RejectedConnections returns number of connections, which are rejected
+// because MaximumConnections was reached.
+func (s *Server) RejectedConnections() uint64 {
+	return atomic.LoadUint64(&s.rejected)
+}
+
+// wrapListener wraps listener with connection limits and PROXY protocol
+// parsing of configuration.
+func (s *Server) wrapListener(l *listener, trusted []*net.IPNet) net.Listener {
+	ln := l.ln
+	if s.conf.MaximumConnections > 0 {
+		ln = &limitListener{
+			Listener: ln,
+			name:     l.name,
+			max:      int64(s.conf.MaximumConnections),
+			rejected: &s.rejected,
+		}
+	}
+	if s.conf.ProxyProtocol.Enabled {
+		ln = &proxyListener{
+			Listener: ln,
+			trusted:  trusted,
+			timeout:  s.conf.ProxyProtocol.HeaderTimeout,
+			optional: s.conf.ProxyProtocol.Optional,
+		}
+	}
+	return ln
+}
+
+// limitListener closes accepted connections immediately, when maximum
+// number of concurrent connections is reached.
+type limitListener struct {
+	net.Listener
+	name     string
+	max      int64
+	active   int64
+	rejected *uint64
+}
+
+// Accept waits next connection, which fits to connection limit
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if atomic.AddInt64(&l.active, 1) <= l.max {
+			return &limitConn{Conn: conn, release: l.release}, nil
+		}
+
+		atomic.AddInt64(&l.active, -1)
+		total := atomic.AddUint64(l.rejected, 1)
+		logging.NewLogger().AddFields(logging.Fields{
+			"listener":             l.name,
+			"remote_addr":          conn.RemoteAddr().String(),
+			"max_connections":      l.max,
+			"rejected_connections": total,
+		}).Error("Connection rejected, maximum number of connections reached")
+		conn.Close()
+	}
+}
+
+// release frees connection slot
+func (l *limitListener) release() {
+	atomic.AddInt64(&l.active, -1)
+}
+
+// limitConn releases its slot, when it is closed
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close closes connection and releases its slot once
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestApplyLimits(t *testing.T) {
+	conf := NewConfiguration()
+	conf.ReadTimeout = 5 * time.Second
+	conf.WriteTimeout = 40 * time.Second
+	s := NewServer(conf)
+
+	hs := &http.Server{IdleTimeout: time.Second}
+	s.applyLimits(hs)
+
+	if hs.ReadHeaderTimeout != conf.ReadHeaderTimeout {
+		t.Errorf("incorrect ReadHeaderTimeout: %s", hs.ReadHeaderTimeout)
+	}
+	if hs.ReadTimeout != conf.ReadTimeout {
+		t.Errorf("incorrect ReadTimeout: %s", hs.ReadTimeout)
+	}
+	if hs.WriteTimeout != conf.WriteTimeout {
+		t.Errorf("incorrect WriteTimeout: %s", hs.WriteTimeout)
+	}
+	if hs.IdleTimeout != time.Second {
+		t.Errorf("IdleTimeout of server is overridden: %s", hs.IdleTimeout)
+	}
+	if hs.MaxHeaderBytes != conf.MaxHeaderBytes {
+		t.Errorf("incorrect MaxHeaderBytes: %d", hs.MaxHeaderBytes)
+	}
+}
+
+func TestServerMaximumConnections(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	addr := freeAddress(t)
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	conf.MaximumConnections = 1
+	s := NewServer(conf, WithAddress(addr))
+	s.Echo().GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, "")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+
+	for i := 0; i < 50 && !s.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// First connection reserves only slot
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("cannot connect: %s", err.Error())
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Second connection is closed by server
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("cannot connect: %s", err.Error())
+	}
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Errorf("connection over limit is not closed")
+	}
+	second.Close()
+	if n := s.RejectedConnections(); n != 1 {
+		t.Errorf("incorrect number of rejected connections, expected: %d, got: %d", 1, n)
+	}
+
+	// Slot is released, when connection is closed
+	first.Close()
+	if resp, err := getWithRetry("http://" + addr + "/test"); err != nil {
+		t.Errorf("request failed: %s", err.Error())
+	} else if resp.StatusCode != http.StatusOK {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,318 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astota/go-logging"
+)
+
+// DefaultListenerName is name of listener, which serves server's
+// HTTP server.
+const DefaultListenerName = "default"
+
+// Listener defines named listener, which is served by Server in addition
+// to default listener. Example admin port with pprof and health handlers.
+type Listener struct {
+	// Name identifies listener in logs and errors
+	Name string
+	// Addr is address where listener listens. Example ":9090" or
+	// "unix:///var/run/admin.sock"
+	Addr string
+	// Handler handles requests of listener. If nil, server handler is used.
+	Handler http.Handler
+	// TLSConfig enables TLS for listener, when defined.
+	TLSConfig *tls.Config
+}
+
+// WithListener adds named listener to server. All listeners are started
+// and stopped together. Default listener is started only if its address
+// is defined or there is no other listeners.
+func WithListener(l Listener) Option {
+	return func(s *Server) {
+		s.listeners = append(s.listeners, l)
+	}
+}
+
+// listener is running listener of server
+type listener struct {
+	name   string
+	server *http.Server
+	tls    bool
+	ln     net.Listener
+	// Handlers of h2c connections, when h2c is enabled
+	h2c *activeHandlers
+}
+
+// listen opens network listener. Addresses with unix:// prefix are
+// opened as Unix domain sockets with given file mode.
+func (l *listener) listen(mode os.FileMode) error {
+	addr := l.server.Addr
+	if addr == "" {
+		addr = ":http"
+		if l.tls {
+			addr = ":https"
+		}
+	}
+
+	var ln net.Listener
+	var err error
+	if strings.HasPrefix(addr, unixScheme) {
+		ln, err = listenUnix(strings.TrimPrefix(addr, unixScheme), mode)
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("listener %s: %w", l.name, err)
+	}
+	l.ln = ln
+	return nil
+}
+
+// serve serves requests of given listener until server is closed
+func (l *listener) serve(ln net.Listener) error {
+	var err error
+	if l.tls {
+		err = l.server.ServeTLS(ln, "", "")
+	} else {
+		err = l.server.Serve(ln)
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return fmt.Errorf("listener %s: %w", l.name, err)
+}
+
+// runningListeners creates listeners, which are served by server
+func (s *Server) runningListeners(useTLS bool) ([]*listener, error) {
+	var ls []*listener
+	if s.server.Addr != "" || len(s.listeners) == 0 {
+		// Make custom initializations to request
+		s.server.Handler = s.Handler()
+		ls = append(ls, &listener{
+			name:   DefaultListenerName,
+			server: s.server,
+			tls:    useTLS,
+		})
+	}
+
+	names := map[string]bool{DefaultListenerName: true}
+	for _, l := range s.listeners {
+		if names[l.Name] {
+			return nil, fmt.Errorf("listener name '%s' is not unique", l.Name)
+		}
+		names[l.Name] = true
+
+		h := l.Handler
+		if h == nil {
+			h = s.handler
+		}
+		ls = append(ls, &listener{
+			name: l.Name,
+			server: &http.Server{
+				Addr:      l.Addr,
+				Handler:   s.InitRequest(h),
+				TLSConfig: l.TLSConfig,
+			},
+			tls: l.TLSConfig != nil,
+		})
+	}
+
+	for _, l := range ls {
+		s.applyLimits(l.server)
+		if l.server.ConnContext == nil {
+			l.server.ConnContext = withConn
+		}
+	}
+
+	return ls, nil
+}
+
+// run starts all listeners and blocks until context is done or one of
+// listeners fails. All listeners are then shutdown gracefully.
+func (s *Server) run(ctx context.Context, useTLS bool) error {
+	logger := logging.NewLogger()
+	// Inherited listeners of other servers are not closed and readiness is
+	// not reported before this server has taken its own and is ready.
+	// Servers, which are only used as middleware, are not waited.
+	s.registerOnce.Do(inherited.register)
+	defer s.claimOnce.Do(inherited.claimed)
+
+	// Server can be stopped by restart in addition to context
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	// Request initialization panics on invalid configuration, so it is
+	// validated before handlers are created
+	if _, err := parseCIDRs(s.conf.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	if _, err := newRequestMapper(s.conf.RequestMappings, nil); err != nil {
+		return err
+	}
+
+	ls, err := s.runningListeners(useTLS)
+	if err != nil {
+		return err
+	}
+	if useTLS {
+		if err := s.configureTLS(ctx); err != nil {
+			return err
+		}
+	}
+	trusted, err := parseCIDRs(s.conf.ProxyProtocol.TrustedCIDRs)
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		if err := s.configureHTTP2(l); err != nil {
+			return fmt.Errorf("listener %s: %w", l.name, err)
+		}
+	}
+
+	if err := inherited.load(); err != nil {
+		return err
+	}
+
+	// Startup is all-or-nothing, so close already opened listeners
+	// if some of listeners cannot be opened.
+	for i, l := range ls {
+		if l.ln = inherited.take(l.name, i); l.ln != nil {
+			logger.Infof("Listener %s inherited", l.name)
+			continue
+		}
+		if err := l.listen(s.conf.UnixSocketMode); err != nil {
+			for _, opened := range ls[:i] {
+				opened.ln.Close()
+			}
+			return err
+		}
+	}
+	s.claimOnce.Do(inherited.claimed)
+
+	s.setRunning(ls, stop)
+	defer s.setRunning(nil, nil)
+	s.handleRestartSignals(ctx.Done())
+
+	errs := make(chan error, len(ls))
+	for _, l := range ls {
+		logger.Infof("Listener %s started on %s", l.name, l.ln.Addr())
+		go func(l *listener, ln net.Listener) {
+			errs <- l.serve(ln)
+		}(l, s.wrapListener(l, trusted))
+	}
+
+	// Start hooks are run after listeners are opened, so that probes are
+	// served during warm-up, but server is not ready before hooks are done.
+	var result Errors
+	running := len(ls)
+	started, err := s.runStartHooks(ctx)
+	if err != nil {
+		result = append(result, err)
+	} else {
+		s.setReady(true)
+		s.startOnce.Do(inherited.started)
+		select {
+		case err := <-errs:
+			running--
+			if err != nil {
+				result = append(result, err)
+			}
+		case <-ctx.Done():
+			// Readiness starts failing immediately, but requests are still
+			// served until load balancers have noticed it.
+			if stopped, err := s.drain(errs); stopped {
+				running--
+				if err != nil {
+					result = append(result, err)
+				}
+			}
+		}
+	}
+	defer s.setReady(false)
+
+	logger.Info("Shutting down server...")
+
+	// Time which is waited before forcefully shutdown server.
+	// Kubernetes default between SIGTERM and SIGKILL
+	// is 30s, so shorter time should be configured.
+	sctx, cancel := context.WithTimeout(context.Background(), s.conf.ShutdownGraceTime)
+	defer cancel()
+	result = append(result, shutdownListeners(sctx, ls)...)
+
+	for ; running > 0; running-- {
+		if err := <-errs; err != nil {
+			result = append(result, err)
+		}
+	}
+
+	// Stop hooks are run after listeners are stopped, so that resources
+	// are not released while requests are still handled.
+	result = append(result, s.runStopHooks(started)...)
+
+	if err := result.errorOrNil(); err != nil {
+		return err
+	}
+
+	logger.Info("Server gracefully stopped")
+	return nil
+}
+
+// drain waits remaining drain delay. Draining is stopped early, if one of
+// listeners stops, and then true is returned with listener error.
+func (s *Server) drain(errs <-chan error) (bool, error) {
+	// Without drain delay server is shutdown immediately. Readiness is
+	// already failing, if pre-stop hook has started draining.
+	if s.conf.DrainDelay <= 0 {
+		return false, nil
+	}
+	d := s.drainRemaining()
+	if d <= 0 {
+		return false, nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return false, nil
+	case err := <-errs:
+		return true, err
+	}
+}
+
+// shutdownListeners shutdowns all listeners concurrently, so that all
+// of those are stopped within same grace time.
+func shutdownListeners(ctx context.Context, ls []*listener) Errors {
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	var result Errors
+
+	for _, l := range ls {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			err := l.server.Shutdown(ctx)
+			if err == nil && l.h2c != nil {
+				err = l.h2c.wait(ctx)
+			}
+			if err != nil {
+				mux.Lock()
+				result = append(result, fmt.Errorf("listener %s: could not shutdown gracefully: %w", l.name, err))
+				mux.Unlock()
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestServerMultipleListeners(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	publicAddr := freeAddress(t)
+	adminAddr := freeAddress(t)
+
+	admin := echo.New()
+	admin.GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusAccepted, "admin")
+	})
+
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	s := NewServer(conf,
+		WithAddress(publicAddr),
+		WithListener(Listener{Name: "admin", Addr: adminAddr, Handler: admin}),
+	)
+	s.Echo().GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, "public")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+
+	tests := []struct {
+		name   string
+		addr   string
+		status int
+	}{
+		{"public listener", publicAddr, http.StatusOK},
+		{"admin listener", adminAddr, http.StatusAccepted},
+	}
+	for _, tst := range tests {
+		if resp, err := getWithRetry("http://" + tst.addr + "/test"); err != nil {
+			t.Errorf("%s: request failed: %s", tst.name, err.Error())
+		} else if resp.StatusCode != tst.status {
+			t.Errorf("%s: incorrect status, expected: %d, got: %d", tst.name, tst.status, resp.StatusCode)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("server is not stopped")
+	}
+
+	for _, tst := range tests {
+		if conn, err := net.Dial("tcp", tst.addr); err == nil {
+			conn.Close()
+			t.Errorf("%s: listener is not closed", tst.name)
+		}
+	}
+}
+
+func TestServerListenerStartupFailure(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	defer busy.Close()
+
+	addr := freeAddress(t)
+	s := NewServer(NewConfiguration(),
+		WithAddress(addr),
+		WithListener(Listener{Name: "admin", Addr: busy.Addr().String()}),
+	)
+	if err := s.RunContext(context.Background()); err == nil {
+		t.Errorf("error is not returned when listener cannot be opened")
+	}
+
+	// Already opened listeners must be closed
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Errorf("default listener is not closed: %s", err.Error())
+	} else {
+		ln.Close()
+	}
+}
+
+func TestServerListenerNames(t *testing.T) {
+	s := NewServer(NewConfiguration(),
+		WithListener(Listener{Name: "admin", Addr: freeAddress(t)}),
+		WithListener(Listener{Name: "admin", Addr: freeAddress(t)}),
+	)
+	if err := s.RunContext(context.Background()); err == nil {
+		t.Errorf("error is not returned for duplicate listener names")
+	}
+}
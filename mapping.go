@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/astota/go-logging"
+)
+
+// Sources of mapped request values
+const (
+	SourceHeader = "header"
+	SourceQuery  = "query"
+	SourceCookie = "cookie"
+)
+
+// OrganizationIDAttribute is attribute, which is also set to OrganizationID
+// of DefaultContext.
+const OrganizationIDAttribute = "organization_id"
+
+// RequestMapping maps value of request header, query parameter or cookie
+// to logger field, DefaultContext attribute and span tag.
+type RequestMapping struct {
+	// Source of value: header, query or cookie. Default: header
+	Source string `yaml:"source" json:"source"`
+	// Name of header, query parameter or cookie
+	Name string `yaml:"name" json:"name"`
+	// Logger field, where value is added. Not logged, if empty
+	Field string `yaml:"field" json:"field"`
+	// DefaultContext attribute, where value is stored. Not stored, if empty
+	Attribute string `yaml:"attribute" json:"attribute"`
+	// Span tag set by RequestTracer. Not tagged, if empty
+	Tag string `yaml:"tag" json:"tag"`
+	// Request is rejected with 400, if value is missing
+	Required bool `yaml:"required" json:"required"`
+	// Regular expression, which value must match. Request is rejected
+	// with 400, if value does not match.
+	Pattern string `yaml:"pattern" json:"pattern"`
+	// Replace value with its SHA-256 hash
+	Hash bool `yaml:"hash" json:"hash"`
+}
+
+// defaultRequestMappings returns mappings of BMG headers
+func defaultRequestMappings() []RequestMapping {
+	return []RequestMapping{
+		{Name: "BMG-Retailer-Api-Key", Field: "retailer_api_key"},
+		{Name: "BMG-Api-Key", Field: "api_key"},
+		{Name: "BMG-Auth-Token", Field: "auth_token"},
+		{Name: "BMG-Organization-Id", Field: "organization_id", Attribute: OrganizationIDAttribute},
+	}
+}
+
+// mappedValues contains values of request mappings
+type mappedValues struct {
+	fields     logging.Fields
+	attributes map[string]string
+	tags       map[string]string
+}
+
+// requestMapper extracts mapped values from requests
+type requestMapper struct {
+	mappings []RequestMapping
+	patterns []*regexp.Regexp
+	rd       *redactor
+}
+
+// newRequestMapper compiles patterns of mappings. Logger fields are
+// redacted with given redactor. Error is returned, if pattern is invalid.
+func newRequestMapper(mappings []RequestMapping, rd *redactor) (*requestMapper, error) {
+	m := &requestMapper{
+		mappings: mappings,
+		patterns: make([]*regexp.Regexp, len(mappings)),
+		rd:       rd,
+	}
+	for i, mapping := range mappings {
+		if mapping.Pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(mapping.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of request mapping %s: %w", mapping.Name, err)
+		}
+		m.patterns[i] = re
+	}
+	return m, nil
+}
+
+// values returns mapped values of request. Error is returned, if required
+// value is missing or value does not match pattern.
+func (m *requestMapper) values(r *http.Request) (mappedValues, error) {
+	v := mappedValues{fields: logging.Fields{}}
+
+	for i, mapping := range m.mappings {
+		value := mapping.value(r)
+		if value == "" {
+			if mapping.Required {
+				return v, fmt.Errorf("missing %s %s", mapping.source(), mapping.Name)
+			}
+			continue
+		}
+		if re := m.patterns[i]; re != nil && !re.MatchString(value) {
+			return v, fmt.Errorf("invalid %s %s", mapping.source(), mapping.Name)
+		}
+
+		if mapping.Hash {
+			sum := sha256.Sum256([]byte(value))
+			value = hex.EncodeToString(sum[:])
+		}
+
+		var header string
+		if mapping.source() == SourceHeader {
+			header = mapping.Name
+		}
+		if mapping.Field != "" {
+			if field, ok := m.rd.field(mapping.Field, header, value); ok {
+				v.fields[mapping.Field] = field
+			}
+		}
+		if mapping.Attribute != "" {
+			if v.attributes == nil {
+				v.attributes = map[string]string{}
+			}
+			v.attributes[mapping.Attribute] = value
+		}
+		// Tags are redacted with same rule as logger field
+		if mapping.Tag != "" {
+			if tag, ok := m.rd.field(mapping.Field, header, value); ok {
+				if v.tags == nil {
+					v.tags = map[string]string{}
+				}
+				v.tags[mapping.Tag] = tag
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// source returns source of mapping, header is default
+func (mapping RequestMapping) source() string {
+	if mapping.Source == "" {
+		return SourceHeader
+	}
+	return mapping.Source
+}
+
+// value returns value of mapping from request
+func (mapping RequestMapping) value(r *http.Request) string {
+	switch mapping.source() {
+	case SourceQuery:
+		return r.URL.Query().Get(mapping.Name)
+	case SourceCookie:
+		if c, err := r.Cookie(mapping.Name); err == nil {
+			return c.Value
+		}
+		return ""
+	default:
+		return r.Header.Get(mapping.Name)
+	}
+}
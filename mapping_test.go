@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+	"github.com/labstack/echo/v4"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestRequestMappings(t *testing.T) {
+	tenant := RequestMapping{Name: "X-Tenant", Field: "tenant", Attribute: "tenant", Tag: "http.tenant"}
+
+	tests := []struct {
+		name       string
+		mappings   []RequestMapping
+		opts       []requestOption
+		status     int
+		fields     map[string]interface{}
+		attributes map[string]string
+		tags       map[string]string
+	}{
+		{"header", []RequestMapping{tenant}, []requestOption{addHeader("X-Tenant", "acme")}, http.StatusOK,
+			map[string]interface{}{"tenant": "acme"}, map[string]string{"tenant": "acme"}, map[string]string{"http.tenant": "acme"}},
+		{"missing optional", []RequestMapping{tenant}, nil, http.StatusOK, nil, nil, nil},
+		{"missing required", []RequestMapping{{Name: "X-Tenant", Required: true}}, nil, http.StatusBadRequest, nil, nil, nil},
+		{"query", []RequestMapping{{Source: SourceQuery, Name: "tenant", Attribute: "tenant"}}, []requestOption{addQuery("tenant", "acme")}, http.StatusOK,
+			nil, map[string]string{"tenant": "acme"}, nil},
+		{"cookie", []RequestMapping{{Source: SourceCookie, Name: "session", Field: "session"}}, []requestOption{addCookie("session", "s1")}, http.StatusOK,
+			map[string]interface{}{"session": "s1"}, nil, nil},
+		{"pattern match", []RequestMapping{{Name: "X-Tenant", Attribute: "tenant", Pattern: `^[a-z]+$`}}, []requestOption{addHeader("X-Tenant", "acme")}, http.StatusOK,
+			nil, map[string]string{"tenant": "acme"}, nil},
+		{"pattern mismatch", []RequestMapping{{Name: "X-Tenant", Pattern: `^[a-z]+$`}}, []requestOption{addHeader("X-Tenant", "ACME")}, http.StatusBadRequest, nil, nil, nil},
+		{"hash", []RequestMapping{{Name: "X-Tenant", Field: "tenant", Hash: true}}, []requestOption{addHeader("X-Tenant", "acme")}, http.StatusOK,
+			map[string]interface{}{"tenant": "822b33ad87c148a0a20a5ba7cd5ebcaa68d36a18e7aad165554903f52ca82757"}, nil, nil},
+		{"organization id", []RequestMapping{{Name: "X-Org", Attribute: OrganizationIDAttribute}}, []requestOption{addHeader("X-Org", "1000")}, http.StatusOK,
+			nil, map[string]string{OrganizationIDAttribute: "1000"}, nil},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			teardownTest := setupTest(t)
+			defer teardownTest(t)
+
+			conf := NewConfiguration()
+			conf.RequestMappings = tst.mappings
+
+			var fctx DefaultContext
+			var logger logging.Logger
+			h := NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fctx, _ = GetDefaultContext(r.Context())
+				logger = logging.GetLogger(r.Context())
+			}))
+
+			resp := httptest.NewRecorder()
+			opts := append([]requestOption{addHeader("BMG-Request-Id", "mapping")}, tst.opts...)
+			h.ServeHTTP(resp, createRequest(http.MethodGet, opts...))
+			if resp.Code != tst.status {
+				t.Fatalf("incorrect status, expected: %d, got: %d", tst.status, resp.Code)
+			}
+			if tst.status != http.StatusOK {
+				var body errorResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+					t.Fatalf("invalid response: %s", resp.Body.String())
+				}
+				if body.Error == "" || body.RequestID != "mapping" {
+					t.Errorf("incorrect response: %+v", body)
+				}
+				return
+			}
+
+			l := logger.(*loggertest.TestLogger)
+			for k, v := range tst.fields {
+				if l.Fields[k] != v {
+					t.Errorf("incorrect field %s, expected: '%v', got: '%v'", k, v, l.Fields[k])
+				}
+			}
+			if !reflect.DeepEqual(fctx.Attributes, tst.attributes) {
+				t.Errorf("incorrect attributes, expected: %v, got: %v", tst.attributes, fctx.Attributes)
+			}
+			if !reflect.DeepEqual(fctx.tags, tst.tags) {
+				t.Errorf("incorrect tags, expected: %v, got: %v", tst.tags, fctx.tags)
+			}
+			if fctx.OrganizationID != tst.attributes[OrganizationIDAttribute] {
+				t.Errorf("incorrect organization ID: '%s'", fctx.OrganizationID)
+			}
+		})
+	}
+}
+
+func TestRequestMappingInvalidPattern(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("invalid pattern does not panic")
+		}
+	}()
+
+	conf := NewConfiguration()
+	conf.RequestMappings = []RequestMapping{{Name: "X-Tenant", Pattern: "("}}
+	NewServer(conf).InitRequest(http.NotFoundHandler())
+}
+
+func TestRunInvalidRequestMapping(t *testing.T) {
+	conf := NewConfiguration()
+	conf.RequestMappings = []RequestMapping{{Name: "X-Tenant", Pattern: "("}}
+	s := NewServer(conf, WithAddress(freeAddress(t)))
+	if err := s.RunContext(context.Background()); err == nil {
+		t.Errorf("error is not returned for invalid pattern")
+	}
+}
+
+func TestRequestTracerMappedTags(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	req := initTracerTestRequest(t)
+	req = req.WithContext(setDefaultContext(req.Context(), DefaultContext{
+		tags: map[string]string{"http.tenant": "acme"},
+	}))
+	callTracerTestRequest(t, req, func(c echo.Context) error {
+		return c.String(http.StatusOK, "")
+	})
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("incorrect number of spans: %d", len(spans))
+	}
+	if tenant := spans[0].Tag("http.tenant"); tenant != "acme" {
+		t.Errorf("incorrect http.tenant tag: %v", tenant)
+	}
+}
+
+func addQuery(key, value string) requestOption {
+	return func(r *http.Request) {
+		q := r.URL.Query()
+		q.Set(key, value)
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+func addCookie(name, value string) requestOption {
+	return func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+}
@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware returns InitRequest as net/http middleware. Configuration set
+// by SetConfiguration is used.
+func Middleware() func(http.Handler) http.Handler {
+	return newServer(getConfiguration()).Middleware()
+}
+
+// EchoMiddleware returns InitRequest as echo middleware. Configuration set
+// by SetConfiguration is used.
+func EchoMiddleware() echo.MiddlewareFunc {
+	return newServer(getConfiguration()).EchoMiddleware()
+}
+
+// Middleware returns request initialization of server as net/http
+// middleware, so it can be used with handlers, which are not served by
+// server. It panics like InitRequest.
+func (s *Server) Middleware() func(http.Handler) http.Handler {
+	return s.initRequest(s.conf.Timeout.Enforce)
+}
+
+// EchoMiddleware returns request initialization of server as echo
+// middleware. It should be first middleware, example added with Pre, so
+// that other middleware have request ID and logger. Structured 413 response
+// is returned, when body limit is exceeded. Handler has its own echo context,
+// when timeouts are enforced, because it can be run after timeout response.
+// It panics like InitRequest.
+func (s *Server) EchoMiddleware() echo.MiddlewareFunc {
+	m := s.initRequest(s.conf.Timeout.Enforce)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		next = BodyLimits()(next)
+		return func(c echo.Context) error {
+			var err error
+			m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if w == http.ResponseWriter(c.Response()) {
+					c.SetRequest(r)
+					err = next(c)
+					return
+				}
+
+				// Context of request is released after timeout response
+				hc := c.Echo().NewContext(r, w)
+				hc.SetPath(c.Path())
+				hc.SetParamNames(append([]string(nil), c.ParamNames()...)...)
+				hc.SetParamValues(append([]string(nil), c.ParamValues()...)...)
+				if err := next(hc); err != nil {
+					hc.Error(err)
+				}
+			})).ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
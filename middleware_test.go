@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+	adapters := []struct {
+		name      string
+		bodyLimit bool
+		timeout   bool
+		handler   func(*Server, http.Handler) http.Handler
+	}{
+		{"net/http", false, true, func(s *Server, h http.Handler) http.Handler {
+			return s.Middleware()(h)
+		}},
+		{"echo", true, true, func(s *Server, h http.Handler) http.Handler {
+			e := echo.New()
+			e.Pre(s.EchoMiddleware())
+			e.Any("/test", echo.WrapHandler(h))
+			return e
+		}},
+		{"gin", true, false, func(s *Server, h http.Handler) http.Handler {
+			gin.SetMode(gin.TestMode)
+			g := gin.New()
+			g.Use(s.GinMiddleware())
+			g.Any("/test", gin.WrapH(h))
+			return g
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		configure func(*Configuration)
+		body      string
+		status    int
+		called    bool
+		bodyLimit bool
+		timeout   bool
+	}{
+		{
+			name:      "initialized",
+			configure: func(conf *Configuration) {},
+			status:    http.StatusOK,
+			called:    true,
+		},
+		{
+			name: "rejected",
+			configure: func(conf *Configuration) {
+				conf.RequestMappings = []RequestMapping{{Name: "BMG-Tenant", Required: true}}
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "body too large",
+			configure: func(conf *Configuration) {
+				conf.MaximumBodySize = 5
+			},
+			body:      "1234567890",
+			status:    http.StatusRequestEntityTooLarge,
+			called:    true,
+			bodyLimit: true,
+		},
+		{
+			name: "timeout",
+			configure: func(conf *Configuration) {
+				conf.MaximumRequestDuration = 10 * time.Millisecond
+				conf.Timeout.Enforce = true
+			},
+			status:  http.StatusGatewayTimeout,
+			called:  true,
+			timeout: true,
+		},
+	}
+
+	for _, a := range adapters {
+		for _, tst := range tests {
+			// Handler can be run after test case
+			a, tst := a, tst
+			t.Run(a.name+" "+tst.name, func(t *testing.T) {
+				teardownTest := setupTest(t)
+				defer teardownTest(t)
+
+				conf := NewConfiguration()
+				conf.RequestIDResponseHeader = "X-Request-Id"
+				tst.configure(&conf)
+
+				ids := make(chan string, 1)
+				release := make(chan struct{})
+				defer close(release)
+				h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fctx, _ := GetDefaultContext(r.Context())
+					ids <- fctx.RequestID
+
+					// Errors are left to middleware
+					ioutil.ReadAll(r.Body)
+					if tst.timeout {
+						<-r.Context().Done()
+						// Timeout response is written before handler returns
+						if a.timeout {
+							<-release
+						}
+						w.WriteHeader(http.StatusNoContent)
+					}
+				})
+
+				req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tst.body))
+				req.Header.Set("BMG-Request-Id", "middleware")
+				resp := httptest.NewRecorder()
+				a.handler(NewServer(conf), h).ServeHTTP(resp, req)
+
+				status := tst.status
+				if tst.bodyLimit && !a.bodyLimit {
+					status = http.StatusOK
+				}
+				if tst.timeout && !a.timeout {
+					status = http.StatusNoContent
+				}
+				if resp.Code != status {
+					t.Errorf("incorrect status, expected: %d, got: %d", status, resp.Code)
+				}
+				if id := resp.Header().Get("X-Request-Id"); id != "middleware" {
+					t.Errorf("incorrect response header, expected: 'middleware', got: '%s'", id)
+				}
+				if l := logging.NewLogger().(*loggertest.TestLogger); l.Fields["request_id"] != "middleware" {
+					t.Errorf("request ID is not logged: %v", l.Fields)
+				}
+
+				if !tst.called {
+					select {
+					case <-ids:
+						t.Errorf("handler of rejected request is called")
+					default:
+					}
+					return
+				}
+				select {
+				case id := <-ids:
+					if id != "middleware" {
+						t.Errorf("incorrect request ID in handler, expected: 'middleware', got: '%s'", id)
+					}
+				case <-time.After(time.Second):
+					t.Errorf("handler is not called")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Client authentication modes of TLS configuration
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// ClientIdentity contains verified identity of client certificate
+type ClientIdentity struct {
+	// Subject common name of client certificate
+	CommonName string
+	// SPIFFE ID from URI SAN of client certificate
+	SPIFFEID string
+}
+
+// ClientCertPolicy defines if route requires client certificate
+type ClientCertPolicy int
+
+const (
+	// ClientCertOptional accepts requests without client certificate
+	ClientCertOptional ClientCertPolicy = iota
+	// ClientCertRequired rejects requests without verified client certificate
+	ClientCertRequired
+)
+
+// configureClientAuth sets client certificate verification to TLS
+// configuration.
+func (c TLSConfiguration) configureClientAuth(cfg *tls.Config) error {
+	switch c.ClientAuth {
+	case "", ClientAuthNone:
+		return nil
+	case ClientAuthOptional:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return fmt.Errorf("invalid client auth mode '%s'", c.ClientAuth)
+	}
+
+	if c.ClientCAFile == "" {
+		return fmt.Errorf("client CA file is not defined")
+	}
+	d, err := ioutil.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return fmt.Errorf("cannot read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(d) {
+		return fmt.Errorf("no certificates found in client CA file '%s'", c.ClientCAFile)
+	}
+	cfg.ClientCAs = pool
+
+	return nil
+}
+
+// clientIdentity returns identity of verified client certificate. Empty
+// identity is returned, if client certificate is not verified.
+func clientIdentity(r *http.Request) ClientIdentity {
+	var id ClientIdentity
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return id
+	}
+
+	leaf := r.TLS.VerifiedChains[0][0]
+	id.CommonName = leaf.Subject.CommonName
+	for _, u := range leaf.URIs {
+		if u.Scheme == "spiffe" {
+			id.SPIFFEID = u.String()
+			break
+		}
+	}
+
+	return id
+}
+
+// clientVerified tells if client certificate chain is verified. Identity
+// can be empty, example when certificate has only DNS SANs.
+func clientVerified(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
+
+// ClientCertificate returns middleware, which enforces client certificate
+// policy of route. Client certificates must be verified on TLS level, so
+// client_auth should be "optional", when only some routes require those.
+func ClientCertificate(policy ClientCertPolicy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if policy == ClientCertRequired && !clientVerified(c.Request()) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "client certificate required")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+	"github.com/labstack/echo/v4"
+)
+
+func addClientCertificate(cn, spiffeID string) requestOption {
+	return func(r *http.Request) {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		if spiffeID != "" {
+			u, _ := url.Parse(spiffeID)
+			cert.URIs = []*url.URL{u}
+		}
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}
+	}
+}
+
+func TestInitRequestClientIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []requestOption
+		expected ClientIdentity
+	}{
+		{"no TLS", nil, ClientIdentity{}},
+		{"common name", []requestOption{addClientCertificate("client", "")}, ClientIdentity{CommonName: "client"}},
+		{"SPIFFE ID", []requestOption{addClientCertificate("client", "spiffe://example.org/ns/default/sa/client")}, ClientIdentity{CommonName: "client", SPIFFEID: "spiffe://example.org/ns/default/sa/client"}},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			handler := func(c echo.Context) error {
+				fctx, err := GetDefaultContext(c.Request().Context())
+				if err != nil {
+					t.Fatalf("default context missing")
+				}
+				if fctx.ClientIdentity != tst.expected {
+					t.Errorf("incorrect client identity, expected: %v, got: %v", tst.expected, fctx.ClientIdentity)
+				}
+
+				l, ok := logging.GetLogger(c.Request().Context()).(*loggertest.TestLogger)
+				if !ok {
+					t.Fatalf("incorrect logger type")
+				}
+				if cn, _ := l.Fields["client_cn"]; tst.expected.CommonName != "" && cn != tst.expected.CommonName {
+					t.Errorf("incorrect client_cn, expected: '%s', got: '%v'", tst.expected.CommonName, cn)
+				}
+				if id, _ := l.Fields["client_spiffe_id"]; tst.expected.SPIFFEID != "" && id != tst.expected.SPIFFEID {
+					t.Errorf("incorrect client_spiffe_id, expected: '%s', got: '%v'", tst.expected.SPIFFEID, id)
+				}
+				return c.String(http.StatusOK, "")
+			}
+
+			callEchoHandler(t, http.MethodGet, handler, nil, createRequest(http.MethodGet, tst.opts...))
+		})
+	}
+}
+
+func TestClientCertificatePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ClientCertPolicy
+		opts   []requestOption
+		status int
+	}{
+		{"optional without certificate", ClientCertOptional, nil, http.StatusOK},
+		{"required without certificate", ClientCertRequired, nil, http.StatusUnauthorized},
+		{"required with certificate", ClientCertRequired, []requestOption{addClientCertificate("client", "")}, http.StatusOK},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			app := echo.New()
+			app.GET("/test", func(c echo.Context) error {
+				return c.String(http.StatusOK, "")
+			}, ClientCertificate(tst.policy))
+
+			resp := httptest.NewRecorder()
+			app.ServeHTTP(resp, createRequest(http.MethodGet, tst.opts...))
+			if resp.Code != tst.status {
+				t.Errorf("incorrect status, expected: %d, got: %d", tst.status, resp.Code)
+			}
+		})
+	}
+}
+
+func TestConfigureClientAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rest-tls")
+	if err != nil {
+		t.Fatalf("cannot create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	caFile, _ := writeTestCertificate(t, dir, "ca")
+
+	tests := []struct {
+		name     string
+		conf     TLSConfiguration
+		expected tls.ClientAuthType
+		wantErr  bool
+	}{
+		{"none", TLSConfiguration{ClientAuth: ClientAuthNone}, tls.NoClientCert, false},
+		{"optional", TLSConfiguration{ClientAuth: ClientAuthOptional, ClientCAFile: caFile}, tls.VerifyClientCertIfGiven, false},
+		{"require", TLSConfiguration{ClientAuth: ClientAuthRequire, ClientCAFile: caFile}, tls.RequireAndVerifyClientCert, false},
+		{"missing CA", TLSConfiguration{ClientAuth: ClientAuthRequire}, tls.NoClientCert, true},
+		{"invalid mode", TLSConfiguration{ClientAuth: "invalid"}, tls.NoClientCert, true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			cfg := &tls.Config{}
+			err := tst.conf.configureClientAuth(cfg)
+			if (err != nil) != tst.wantErr {
+				t.Fatalf("unexpected error value: %v", err)
+			}
+			if err == nil && cfg.ClientAuth != tst.expected {
+				t.Errorf("incorrect client auth, expected: %v, got: %v", tst.expected, cfg.ClientAuth)
+			}
+		})
+	}
+}
+
+func TestConfigureTLSClientAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rest-tls")
+	if err != nil {
+		t.Fatalf("cannot create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	caFile, _ := writeTestCertificate(t, dir, "ca")
+
+	tests := []struct {
+		name     string
+		conf     TLSConfiguration
+		base     *tls.Config
+		expected tls.ClientAuthType
+		wantErr  bool
+	}{
+		{"no client auth", TLSConfiguration{}, nil, tls.NoClientCert, false},
+		{"server TLS config", TLSConfiguration{ClientAuth: ClientAuthRequire, ClientCAFile: caFile}, &tls.Config{}, tls.RequireAndVerifyClientCert, false},
+		{"missing CA file", TLSConfiguration{ClientAuth: ClientAuthRequire, ClientCAFile: dir + "/missing.crt"}, &tls.Config{}, tls.NoClientCert, true},
+		{"no TLS config", TLSConfiguration{ClientAuth: ClientAuthRequire, ClientCAFile: caFile}, nil, tls.NoClientCert, true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			conf := NewConfiguration()
+			conf.TLS = tst.conf
+			s := NewServer(conf, WithHTTPServer(&http.Server{TLSConfig: tst.base}))
+
+			err := s.configureTLS(context.Background())
+			if (err != nil) != tst.wantErr {
+				t.Fatalf("unexpected error value: %v", err)
+			}
+			if err != nil || tst.base == nil {
+				return
+			}
+			if s.HTTPServer().TLSConfig.ClientAuth != tst.expected || s.HTTPServer().TLSConfig.ClientCAs == nil {
+				t.Errorf("client auth is not configured: %v", s.HTTPServer().TLSConfig.ClientAuth)
+			}
+		})
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []requestOption
+		status int
+	}{
+		{"no TLS", nil, http.StatusUnauthorized},
+		{"unverified certificate", []requestOption{func(r *http.Request) {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+		}}, http.StatusUnauthorized},
+		{"verified without identity", []requestOption{addClientCertificate("", "")}, http.StatusOK},
+		{"verified with identity", []requestOption{addClientCertificate("client", "")}, http.StatusOK},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			e := echo.New()
+			e.GET("/test", func(c echo.Context) error {
+				return c.String(http.StatusOK, "")
+			}, ClientCertificate(ClientCertRequired))
+
+			resp := httptest.NewRecorder()
+			e.ServeHTTP(resp, createRequest(http.MethodGet, tst.opts...))
+			if resp.Code != tst.status {
+				t.Errorf("incorrect status, expected: %d, got: %d", tst.status, resp.Code)
+			}
+		})
+	}
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	dir, err := ioutil.TempDir("", "rest-tls")
+	if err != nil {
+		t.Fatalf("cannot create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	clientCert, clientKey := writeTestCertificate(t, dir, "client")
+	addr := freeAddress(t)
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	conf.TLS.CertFile, conf.TLS.KeyFile = writeTestCertificate(t, dir, "localhost")
+	conf.TLS.ClientAuth = ClientAuthOptional
+	conf.TLS.ClientCAFile = clientCert
+
+	var identity ClientIdentity
+	s := NewServer(conf, WithAddress(addr))
+	s.Echo().GET("/test", func(c echo.Context) error {
+		fctx, _ := GetDefaultContext(c.Request().Context())
+		identity = fctx.ClientIdentity
+		return c.String(http.StatusOK, "")
+	}, ClientCertificate(ClientCertRequired))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunTLSContext(ctx)
+	}()
+
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatalf("cannot load client certificate: %s", err.Error())
+	}
+	tests := []struct {
+		name   string
+		certs  []tls.Certificate
+		status int
+	}{
+		{"without client certificate", nil, http.StatusUnauthorized},
+		{"with client certificate", []tls.Certificate{cert}, http.StatusOK},
+	}
+	for _, tst := range tests {
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, Certificates: tst.certs},
+				DisableKeepAlives: true,
+			},
+		}
+		var resp *http.Response
+		for i := 0; i < 50; i++ {
+			if resp, err = client.Get("https://" + addr + "/test"); err == nil {
+				resp.Body.Close()
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Errorf("%s: request failed: %s", tst.name, err.Error())
+		} else if resp.StatusCode != tst.status {
+			t.Errorf("%s: incorrect status, expected: %d, got: %d", tst.name, tst.status, resp.StatusCode)
+		}
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if identity.CommonName != "client" {
+		t.Errorf("incorrect client identity: %v", identity)
+	}
+}
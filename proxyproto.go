@@ -0,0 +1,287 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyProtocolConfiguration contains PROXY protocol settings
+type ProxyProtocolConfiguration struct {
+	// Parse PROXY protocol v1 and v2 headers. Default: false
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Source networks, which are allowed to send PROXY protocol header,
+	// example 10.0.0.0/8. Headers from other sources are not parsed.
+	TrustedCIDRs []string `yaml:"trusted_cidrs" json:"trusted_cidrs"`
+	// Maximum duration for reading PROXY protocol header. Default: 5s
+	HeaderTimeout time.Duration `yaml:"header_timeout" json:"header_timeout"`
+	// Accept connections without header from trusted sources. By default
+	// those are closed, so that clients cannot send their own header
+	// through proxy, which forwards raw connections. Default: false
+	Optional bool `yaml:"optional" json:"optional"`
+}
+
+// PROXY protocol v2 signature
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Maximum length of PROXY protocol v1 header
+const proxyV1MaxLength = 107
+
+// errProxyHeaderMissing is returned, when required header is not sent
+var errProxyHeaderMissing = errors.New("PROXY protocol header missing")
+
+// parseCIDRs parses list of networks
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s': %w", cidr, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// containsIP tells if ip is in one of networks
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyListener parses PROXY protocol headers of accepted connections
+type proxyListener struct {
+	net.Listener
+	trusted  []*net.IPNet
+	timeout  time.Duration
+	optional bool
+}
+
+// Accept returns connection, which parses PROXY protocol header on first
+// use. Header is not read here, so that slow clients do not block Accept.
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !containsIP(l.trusted, tcpAddr.IP) {
+		return conn, nil
+	}
+
+	return &proxyConn{
+		Conn:     conn,
+		reader:   bufio.NewReader(conn),
+		timeout:  l.timeout,
+		optional: l.optional,
+	}, nil
+}
+
+// proxyAddr is client address received in PROXY protocol header
+type proxyAddr struct {
+	net.Addr
+}
+
+// proxyConn is connection from trusted proxy
+type proxyConn struct {
+	net.Conn
+	reader   *bufio.Reader
+	timeout  time.Duration
+	optional bool
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// init reads PROXY protocol header. Connection is closed, if header is
+// invalid or required header is missing.
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+
+		var addr net.Addr
+		addr, c.err = readProxyHeader(c.reader, c.optional)
+		if c.err != nil {
+			c.Conn.Close()
+			return
+		}
+		if addr != nil {
+			c.remote = &proxyAddr{Addr: addr}
+		}
+	})
+}
+
+// Read reads data after PROXY protocol header
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns client address of PROXY protocol header or peer
+// address, if header is not sent.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads PROXY protocol v1 or v2 header. Nil address is
+// returned, if header does not contain address or if optional header is
+// not sent.
+func readProxyHeader(r *bufio.Reader, optional bool) (net.Addr, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		if err == io.EOF && optional {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	switch b[0] {
+	case 'P':
+		if b, err := r.Peek(6); err == nil && string(b) == "PROXY " {
+			return readProxyV1(r)
+		}
+	case proxyV2Signature[0]:
+		if b, err := r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(b, proxyV2Signature) {
+			return readProxyV2(r)
+		}
+	}
+
+	if optional {
+		return nil, nil
+	}
+	return nil, errProxyHeaderMissing
+}
+
+// readProxyV1 parses text format header, example
+// "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROXY protocol header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("invalid PROXY protocol header: too long")
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY protocol header: %q", strings.TrimSpace(string(line)))
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid PROXY protocol source address")
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readProxyV2 parses binary format header
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol header: %w", err)
+	}
+
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("invalid PROXY protocol version %d", header[12]>>4)
+	}
+	command := header[12] & 0x0f
+	family := header[13] >> 4
+	length := binary.BigEndian.Uint16(header[14:16])
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol header: %w", err)
+	}
+
+	// LOCAL command is used example in health checks of proxy
+	if command == 0 {
+		return nil, nil
+	} else if command != 1 {
+		return nil, fmt.Errorf("invalid PROXY protocol command %d", command)
+	}
+
+	switch family {
+	case 1: // IPv4
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("invalid PROXY protocol address length")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:4]),
+			Port: int(binary.BigEndian.Uint16(payload[8:10])),
+		}, nil
+	case 2: // IPv6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("invalid PROXY protocol address length")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:16]),
+			Port: int(binary.BigEndian.Uint16(payload[32:34])),
+		}, nil
+	}
+
+	// Unspecified and Unix addresses are not used as client address
+	return nil, nil
+}
+
+type connContextKey struct{}
+
+// withConn adds connection to context. It is used as ConnContext of
+// HTTP servers.
+func withConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// proxiedIP returns client IP, which is received in PROXY protocol header
+func proxiedIP(r *http.Request) (string, bool) {
+	c, ok := r.Context().Value(connContextKey{}).(net.Conn)
+	if !ok {
+		return "", false
+	}
+
+	// TLS connections return address of underlying connection
+	if addr, ok := c.RemoteAddr().(*proxyAddr); ok {
+		if tcpAddr, ok := addr.Addr.(*net.TCPAddr); ok {
+			return tcpAddr.IP.String(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func proxyV2Header(command, family byte, payload []byte) string {
+	var b bytes.Buffer
+	b.Write(proxyV2Signature)
+	b.WriteByte(0x20 | command)
+	b.WriteByte(family<<4 | 1)
+	binary.Write(&b, binary.BigEndian, uint16(len(payload)))
+	b.Write(payload)
+	return b.String()
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ipv4 := append(append(net.ParseIP("203.0.113.7").To4(), net.ParseIP("10.0.0.1").To4()...), 0xd4, 0x31, 0x01, 0xbb)
+	ipv6 := append(append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...), 0xd4, 0x31, 0x01, 0xbb)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"no header", "GET / HTTP/1.1\r\n", "", true},
+		{"spoofed header after request", "GET / HTTP/1.1\r\nPROXY TCP4 203.0.113.7 10.0.0.1 54321 443\r\n", "", true},
+		{"v1 TCP4", "PROXY TCP4 203.0.113.7 10.0.0.1 54321 443\r\nGET / HTTP/1.1\r\n", "203.0.113.7:54321", false},
+		{"v1 TCP6", "PROXY TCP6 2001:db8::1 2001:db8::2 54321 443\r\nGET / HTTP/1.1\r\n", "[2001:db8::1]:54321", false},
+		{"v1 UNKNOWN", "PROXY UNKNOWN\r\nGET / HTTP/1.1\r\n", "", false},
+		{"v1 invalid address", "PROXY TCP4 invalid 10.0.0.1 54321 443\r\nGET / HTTP/1.1\r\n", "", true},
+		{"v1 too long", "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", "", true},
+		{"v2 IPv4", proxyV2Header(1, 1, ipv4) + "GET / HTTP/1.1\r\n", "203.0.113.7:54321", false},
+		{"v2 IPv6", proxyV2Header(1, 2, ipv6) + "GET / HTTP/1.1\r\n", "[2001:db8::1]:54321", false},
+		{"v2 LOCAL", proxyV2Header(0, 0, nil) + "GET / HTTP/1.1\r\n", "", false},
+		{"v2 short address", proxyV2Header(1, 1, ipv4[:4]) + "GET / HTTP/1.1\r\n", "", true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tst.input))
+			addr, err := readProxyHeader(r, false)
+			if (err != nil) != tst.wantErr {
+				t.Fatalf("unexpected error value: %v", err)
+			}
+			if err != nil {
+				return
+			}
+
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tst.expected {
+				t.Errorf("incorrect address, expected: '%s', got: '%s'", tst.expected, got)
+			}
+
+			// Rest of data is request
+			if rest, _ := ioutil.ReadAll(r); !strings.HasSuffix(tst.input, string(rest)) || !strings.Contains(string(rest), "HTTP/1.1") {
+				t.Errorf("incorrect data after header: %q", rest)
+			}
+		})
+	}
+}
+
+func TestReadOptionalProxyHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"no header", "GET / HTTP/1.1\r\n", ""},
+		{"POST request", "POST / HTTP/1.1\r\n", ""},
+		{"v1 TCP4", "PROXY TCP4 203.0.113.7 10.0.0.1 54321 443\r\nGET / HTTP/1.1\r\n", "203.0.113.7:54321"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tst.input))
+			addr, err := readProxyHeader(r, true)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tst.expected {
+				t.Errorf("incorrect address, expected: '%s', got: '%s'", tst.expected, got)
+			}
+			if rest, _ := ioutil.ReadAll(r); !strings.HasPrefix(string(rest), "GET") && !strings.HasPrefix(string(rest), "POST") {
+				t.Errorf("incorrect data after header: %q", rest)
+			}
+		})
+	}
+}
+
+func TestServerProxyProtocol(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tests := []struct {
+		name     string
+		trusted  []string
+		optional bool
+		header   string
+		status   int
+		userIP   string
+		received string
+	}{
+		{"trusted proxy", []string{"127.0.0.0/8"}, false, "PROXY TCP4 203.0.113.7 127.0.0.1 54321 80\r\n", http.StatusOK, "203.0.113.7", "203.0.113.7"},
+		{"trusted proxy without header", []string{"127.0.0.0/8"}, false, "", 0, "", ""},
+		{"trusted proxy without optional header", []string{"127.0.0.0/8"}, true, "", http.StatusOK, "127.0.0.1", ""},
+		{"untrusted proxy", []string{"10.0.0.0/8"}, false, "PROXY TCP4 203.0.113.7 127.0.0.1 54321 80\r\n", http.StatusBadRequest, "", ""},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			addr := freeAddress(t)
+			conf := NewConfiguration()
+			conf.ShutdownGraceTime = time.Second
+			conf.ProxyProtocol.Enabled = true
+			conf.ProxyProtocol.TrustedCIDRs = tst.trusted
+			conf.ProxyProtocol.Optional = tst.optional
+
+			var remoteIP, forwardedFor string
+			s := NewServer(conf, WithAddress(addr))
+			s.Echo().GET("/test", func(c echo.Context) error {
+				remoteIP = userIP(c.Request())
+				fctx, _ := GetDefaultContext(c.Request().Context())
+				forwardedFor = fctx.ForwardedFor
+				return c.String(http.StatusOK, "")
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errs := make(chan error, 1)
+			go func() {
+				errs <- s.RunContext(ctx)
+			}()
+			for i := 0; i < 50 && !s.Ready(); i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatalf("cannot connect: %s", err.Error())
+			}
+			conn.Write([]byte(tst.header + "GET /test HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			conn.Close()
+			// Connection is closed without response
+			if tst.status == 0 {
+				if err == nil {
+					t.Errorf("response received: %d", resp.StatusCode)
+				}
+			} else if err != nil {
+				t.Fatalf("cannot read response: %s", err.Error())
+			} else {
+				resp.Body.Close()
+				if resp.StatusCode != tst.status {
+					t.Errorf("incorrect status, expected: %d, got: %d", tst.status, resp.StatusCode)
+				}
+			}
+			if remoteIP != tst.userIP {
+				t.Errorf("incorrect user ip, expected: '%s', got: '%s'", tst.userIP, remoteIP)
+			}
+			if forwardedFor != tst.received {
+				t.Errorf("incorrect ForwardedFor, expected: '%s', got: '%s'", tst.received, forwardedFor)
+			}
+
+			cancel()
+			if err := <-errs; err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		})
+	}
+}
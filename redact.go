@@ -0,0 +1,178 @@
+package rest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/astota/go-logging"
+)
+
+// Redaction actions
+const (
+	// RedactDrop removes value from logs
+	RedactDrop = "drop"
+	// RedactMask replaces all but last Keep characters with '*'
+	RedactMask = "mask"
+	// RedactHMAC replaces value with keyed HMAC-SHA256, so that values
+	// can be correlated without revealing them.
+	RedactHMAC = "hmac"
+)
+
+// RedactionRule defines how value is redacted. Unknown actions drop value.
+type RedactionRule struct {
+	// Action is drop, mask or hmac
+	Action string `yaml:"action" json:"action"`
+	// Number of last characters, which are not masked
+	Keep int `yaml:"keep" json:"keep"`
+}
+
+// RedactionConfiguration defines how request headers and logger fields
+// are redacted in logs.
+type RedactionConfiguration struct {
+	// Key of HMAC action. If key is not defined, HMAC values are dropped.
+	HMACKey string `yaml:"hmac_key" json:"hmac_key"`
+	// Rules of request headers. Rules are used in request dumps and in
+	// logger fields of request mappings. Default: Authorization,
+	// Proxy-Authorization and Cookie are dropped and BMG keys and tokens
+	// are masked.
+	Headers map[string]RedactionRule `yaml:"headers" json:"headers"`
+	// Rules of logger fields. Field rule is used instead of header rule,
+	// if both are defined.
+	Fields map[string]RedactionRule `yaml:"fields" json:"fields"`
+}
+
+// defaultRedactedHeaders returns rules of headers, which contain secrets
+func defaultRedactedHeaders() map[string]RedactionRule {
+	return map[string]RedactionRule{
+		"Authorization":        {Action: RedactDrop},
+		"Proxy-Authorization":  {Action: RedactDrop},
+		"Cookie":               {Action: RedactDrop},
+		"BMG-Api-Key":          {Action: RedactMask, Keep: 4},
+		"BMG-Retailer-Api-Key": {Action: RedactMask, Keep: 4},
+		"BMG-Auth-Token":       {Action: RedactMask, Keep: 4},
+	}
+}
+
+var redactorKey contextKey = "Redactor"
+
+// redactor redacts values according to redaction configuration
+type redactor struct {
+	key     []byte
+	headers map[string]RedactionRule
+	fields  map[string]RedactionRule
+}
+
+// newRedactor creates redactor. Default header rules are used, if header
+// rules are not defined.
+func newRedactor(conf RedactionConfiguration) *redactor {
+	headers := conf.Headers
+	if headers == nil {
+		headers = defaultRedactedHeaders()
+	}
+
+	rd := &redactor{
+		key:     []byte(conf.HMACKey),
+		headers: make(map[string]RedactionRule, len(headers)),
+		fields:  conf.Fields,
+	}
+	for name, rule := range headers {
+		rd.headers[http.CanonicalHeaderKey(name)] = rule
+	}
+	return rd
+}
+
+// apply redacts value with rule. False is returned, if value is dropped.
+func (rd *redactor) apply(rule RedactionRule, value string) (string, bool) {
+	switch rule.Action {
+	case RedactMask:
+		return maskValue(value, rule.Keep), true
+	case RedactHMAC:
+		if len(rd.key) == 0 {
+			return "", false
+		}
+		mac := hmac.New(sha256.New, rd.key)
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil)), true
+	default:
+		return "", false
+	}
+}
+
+// field redacts value of logger field. Header rule is used, if value is
+// from header and field does not have rule.
+func (rd *redactor) field(name, header, value string) (string, bool) {
+	if rule, ok := rd.fields[name]; ok {
+		return rd.apply(rule, value)
+	}
+	if header != "" {
+		if rule, ok := rd.headers[http.CanonicalHeaderKey(header)]; ok {
+			return rd.apply(rule, value)
+		}
+	}
+	return value, true
+}
+
+// logFields returns copy of fields, where field rules are applied
+func (rd *redactor) logFields(fields logging.Fields) logging.Fields {
+	result := make(logging.Fields, len(fields))
+	for name, value := range fields {
+		rule, ok := rd.fields[name]
+		if !ok {
+			result[name] = value
+			continue
+		}
+		if v, ok := rd.apply(rule, fmt.Sprint(value)); ok {
+			result[name] = v
+		}
+	}
+	return result
+}
+
+// header returns copy of headers, where header rules are applied
+func (rd *redactor) header(h http.Header) http.Header {
+	result := make(http.Header, len(h))
+	for name, values := range h {
+		rule, ok := rd.headers[http.CanonicalHeaderKey(name)]
+		if !ok {
+			result[name] = values
+			continue
+		}
+		for _, value := range values {
+			if v, ok := rd.apply(rule, value); ok {
+				result[name] = append(result[name], v)
+			}
+		}
+	}
+	return result
+}
+
+// maskValue replaces all but last keep characters with '*'. Values, which
+// are not longer than keep, are masked completely.
+func maskValue(value string, keep int) string {
+	runes := []rune(value)
+	if keep < 0 || keep >= len(runes) {
+		keep = 0
+	}
+	masked := len(runes) - keep
+	return strings.Repeat("*", masked) + string(runes[masked:])
+}
+
+// withRedactor adds redactor to context, so that middleware can redact
+// their logs.
+func withRedactor(ctx context.Context, rd *redactor) context.Context {
+	return context.WithValue(ctx, redactorKey, rd)
+}
+
+// getRedactor returns redactor of context. Redactor of package level
+// configuration is returned, if request is not initialized.
+func getRedactor(ctx context.Context) *redactor {
+	if rd, ok := ctx.Value(redactorKey).(*redactor); ok {
+		return rd
+	}
+	return newRedactor(getConfiguration().Redaction)
+}
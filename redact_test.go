@@ -0,0 +1,196 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		keep     int
+		expected string
+	}{
+		{"keep last", "secret-1234", 4, "*******1234"},
+		{"mask all", "secret", 0, "******"},
+		{"short value", "abc", 4, "***"},
+		{"equal length", "abcd", 4, "****"},
+		{"multibyte", "ääää", 1, "***ä"},
+		{"empty", "", 4, ""},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			if masked := maskValue(tst.value, tst.keep); masked != tst.expected {
+				t.Errorf("incorrect masked value, expected: '%s', got: '%s'", tst.expected, masked)
+			}
+		})
+	}
+}
+
+func TestRedactorField(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("secret-1234"))
+	hmacValue := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name     string
+		conf     RedactionConfiguration
+		field    string
+		header   string
+		expected string
+		dropped  bool
+	}{
+		{"no rule", RedactionConfiguration{}, "tenant", "X-Tenant", "secret-1234", false},
+		{"default header rule", RedactionConfiguration{}, "api_key", "BMG-Api-Key", "*******1234", false},
+		{"header rule is case insensitive", RedactionConfiguration{}, "api_key", "bmg-api-key", "*******1234", false},
+		{"field rule", RedactionConfiguration{Fields: map[string]RedactionRule{"tenant": {Action: RedactDrop}}}, "tenant", "", "", true},
+		{"field rule before header rule", RedactionConfiguration{
+			HMACKey: "key",
+			Fields:  map[string]RedactionRule{"api_key": {Action: RedactHMAC}},
+		}, "api_key", "BMG-Api-Key", hmacValue, false},
+		{"hmac without key", RedactionConfiguration{Fields: map[string]RedactionRule{"tenant": {Action: RedactHMAC}}}, "tenant", "", "", true},
+		{"unknown action", RedactionConfiguration{Fields: map[string]RedactionRule{"tenant": {Action: "unknown"}}}, "tenant", "", "", true},
+		{"header rules disabled", RedactionConfiguration{Headers: map[string]RedactionRule{}}, "api_key", "BMG-Api-Key", "secret-1234", false},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			value, ok := newRedactor(tst.conf).field(tst.field, tst.header, "secret-1234")
+			if ok == tst.dropped {
+				t.Fatalf("incorrect drop, expected: %t, got: %t", tst.dropped, !ok)
+			}
+			if value != tst.expected {
+				t.Errorf("incorrect value, expected: '%s', got: '%s'", tst.expected, value)
+			}
+		})
+	}
+}
+
+func TestRedactorHeader(t *testing.T) {
+	rd := newRedactor(RedactionConfiguration{})
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("BMG-Auth-Token", "token-1234")
+	h.Set("User-Agent", "test")
+
+	redacted := rd.header(h)
+	if v := redacted.Get("Authorization"); v != "" {
+		t.Errorf("Authorization is not dropped: '%s'", v)
+	}
+	if v := redacted.Get("BMG-Auth-Token"); v != "******1234" {
+		t.Errorf("BMG-Auth-Token is not masked: '%s'", v)
+	}
+	if v := redacted.Get("User-Agent"); v != "test" {
+		t.Errorf("User-Agent is changed: '%s'", v)
+	}
+	if v := h.Get("Authorization"); v != "Bearer secret" {
+		t.Errorf("original headers are changed")
+	}
+}
+
+func TestInitRequestRedaction(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	conf := NewConfiguration()
+	conf.Redaction.Fields = map[string]RedactionRule{
+		"user_agent": {Action: RedactDrop},
+		"api_key":    {Action: RedactMask, Keep: 2},
+	}
+	conf.RequestMappings = append(conf.RequestMappings,
+		RequestMapping{Name: "BMG-Auth-Token", Tag: "http.auth_token"},
+		RequestMapping{Name: "BMG-Api-Key", Field: "api_key", Tag: "http.api_key"},
+		RequestMapping{Name: "Authorization", Tag: "http.authorization"},
+	)
+
+	var logger logging.Logger
+	var fctx DefaultContext
+	h := NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger = logging.GetLogger(r.Context())
+		fctx, _ = GetDefaultContext(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), createRequest(http.MethodGet,
+		addHeader("User-Agent", "test-agent"),
+		addHeader("BMG-Api-Key", "key-123"),
+		addHeader("BMG-Auth-Token", "token-1234"),
+		addHeader("Authorization", "Bearer secret"),
+	))
+
+	l := logger.(*loggertest.TestLogger)
+	if _, ok := l.Fields["user_agent"]; ok {
+		t.Errorf("user_agent is not dropped")
+	}
+	if v := l.Fields["api_key"]; v != "*****23" {
+		t.Errorf("incorrect api_key: '%v'", v)
+	}
+	if v := l.Fields["auth_token"]; v != "******1234" {
+		t.Errorf("incorrect auth_token: '%v'", v)
+	}
+
+	// Span tags are redacted with same rules
+	if v := fctx.tags["http.auth_token"]; v != "******1234" {
+		t.Errorf("incorrect http.auth_token tag: '%v'", v)
+	}
+	if v := fctx.tags["http.api_key"]; v != "*****23" {
+		t.Errorf("incorrect http.api_key tag: '%v'", v)
+	}
+	if _, ok := fctx.tags["http.authorization"]; ok {
+		t.Errorf("http.authorization tag is not dropped")
+	}
+}
+
+func TestRecoveryRedaction(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var logger logging.Logger
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			logger = logging.GetLogger(c.Request().Context())
+			return next(c)
+		}
+	})
+	e.Use(Recovery)
+	e.GET("/test", func(c echo.Context) error {
+		panic("test panic")
+	})
+
+	req := createRequest(http.MethodGet,
+		addHeader("Authorization", "Bearer secret"),
+		addHeader("BMG-Api-Key", "key-1234"),
+		addQuery("token", "query-secret"),
+	)
+	// Server sets request URI, which is preferred in request dump
+	req.RequestURI = req.URL.RequestURI()
+	resp := httptest.NewRecorder()
+	NewServer(NewConfiguration()).InitRequest(e).ServeHTTP(resp, req)
+	if resp.Code != http.StatusInternalServerError {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusInternalServerError, resp.Code)
+	}
+
+	dump, _ := logger.(*loggertest.TestLogger).Fields["request"].(string)
+	if dump == "" {
+		t.Fatalf("request is not logged")
+	}
+	if strings.Contains(dump, "secret") || strings.Contains(dump, "Authorization") {
+		t.Errorf("Authorization is not dropped: %s", dump)
+	}
+	if !strings.Contains(dump, "Bmg-Api-Key: ****1234") {
+		t.Errorf("BMG-Api-Key is not masked: %s", dump)
+	}
+	if strings.Contains(dump, "query-secret") || !strings.Contains(dump, "GET /test HTTP/1.1") {
+		t.Errorf("query is not removed: %s", dump)
+	}
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultRequestIDHeader is used, when request ID headers are not configured
+const defaultRequestIDHeader = "BMG-Request-Id"
+
+// defaultRequestIDLength is used, when maximum length is not configured
+const defaultRequestIDLength = 128
+
+// requestID returns request ID from first configured header, which has
+// valid ID. Trace ID of W3C traceparent header is used as fallback, if it
+// is enabled. Otherwise new ID is generated.
+func (s *Server) requestID(r *http.Request) string {
+	headers := s.conf.RequestIDHeaders
+	if len(headers) == 0 {
+		headers = []string{defaultRequestIDHeader}
+	}
+	maxLength := s.conf.MaximumRequestIDLength
+	if maxLength <= 0 {
+		maxLength = defaultRequestIDLength
+	}
+
+	for _, h := range headers {
+		if id := r.Header.Get(h); validRequestID(id, maxLength) {
+			return id
+		}
+	}
+
+	if s.conf.RequestIDFromTraceparent {
+		if id := traceparentID(r.Header.Get("traceparent")); id != "" {
+			return id
+		}
+	}
+
+	return s.idGenerator.NewID()
+}
+
+// traceparentID returns trace ID of W3C traceparent header, example
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01. Empty string
+// is returned, if header is not valid.
+func traceparentID(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+	if len(parts[1]) != 32 || !isLowerHex(parts[1]) || strings.Trim(parts[1], "0") == "" {
+		return ""
+	}
+	if len(parts[2]) != 16 || !isLowerHex(parts[2]) {
+		return ""
+	}
+	return parts[1]
+}
+
+// isLowerHex tells if string contains only lower case hexadecimal digits
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
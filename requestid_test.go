@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRequestID(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name           string
+		headers        []string
+		traceparent    bool
+		responseHeader string
+		request        map[string]string
+		expected       string
+	}{
+		{"default header", nil, false, "", map[string]string{"BMG-Request-Id": "bmg-id"}, "bmg-id"},
+		{"first header", []string{"X-Request-Id", "BMG-Request-Id"}, false, "", map[string]string{"X-Request-Id": "x-id", "BMG-Request-Id": "bmg-id"}, "x-id"},
+		{"second header", []string{"X-Request-Id", "BMG-Request-Id"}, false, "", map[string]string{"BMG-Request-Id": "bmg-id"}, "bmg-id"},
+		{"traceparent fallback", nil, true, "", map[string]string{"traceparent": traceparent}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"traceparent disabled", nil, false, "", map[string]string{"traceparent": traceparent}, ""},
+		{"header before traceparent", nil, true, "", map[string]string{"traceparent": traceparent, "BMG-Request-Id": "bmg-id"}, "bmg-id"},
+		{"response header", nil, false, "X-Request-Id", map[string]string{"BMG-Request-Id": "bmg-id"}, "bmg-id"},
+		{"generated with response header", nil, false, "X-Request-Id", nil, ""},
+		{"too long", nil, false, "", map[string]string{"BMG-Request-Id": strings.Repeat("a", 129)}, ""},
+		{"malformed", nil, false, "", map[string]string{"BMG-Request-Id": "id\n injected"}, ""},
+		{"invalid first header", []string{"X-Request-Id", "BMG-Request-Id"}, false, "", map[string]string{"X-Request-Id": "x id", "BMG-Request-Id": "bmg-id"}, "bmg-id"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			teardownTest := setupTest(t)
+			defer teardownTest(t)
+
+			conf := NewConfiguration()
+			conf.RequestIDHeaders = tst.headers
+			conf.RequestIDFromTraceparent = tst.traceparent
+			conf.RequestIDResponseHeader = tst.responseHeader
+
+			var requestID string
+			h := NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fctx, _ := GetDefaultContext(r.Context())
+				requestID = fctx.RequestID
+			}))
+
+			var opts []requestOption
+			for k, v := range tst.request {
+				opts = append(opts, addHeader(k, v))
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, createRequest(http.MethodGet, opts...))
+
+			if tst.expected == "" {
+				if _, err := uuid.Parse(requestID); err != nil {
+					t.Errorf("request ID is not generated: '%s'", requestID)
+				}
+			} else if requestID != tst.expected {
+				t.Errorf("incorrect request ID, expected: '%s', got: '%s'", tst.expected, requestID)
+			}
+
+			if tst.responseHeader != "" && resp.Header().Get(tst.responseHeader) != requestID {
+				t.Errorf("request ID is not in response header, got: '%s'", resp.Header().Get(tst.responseHeader))
+			}
+		})
+	}
+}
+
+func TestTraceparentID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"empty", "", ""},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ""},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", ""},
+		{"short trace id", "00-4bf92f35-00f067aa0ba902b7-01", ""},
+		{"missing flags", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", ""},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			if id := traceparentID(tst.header); id != tst.expected {
+				t.Errorf("incorrect trace ID, expected: '%s', got: '%s'", tst.expected, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/astota/go-logging"
+	"github.com/opentracing/opentracing-go"
+)
+
+// Errors of outbound request policies
+var (
+	// ErrCircuitOpen is returned, when circuit breaker of host is open
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrBulkheadFull is returned, when maximum number of concurrent
+	// outbound requests is reached
+	ErrBulkheadFull = errors.New("too many concurrent outbound requests")
+)
+
+// RetryPolicy defines retries of idempotent outbound requests. Retries
+// are not made, if they would run past deadline of request context.
+type RetryPolicy struct {
+	// Maximum number of attempts including first one. Default: no retries
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts"`
+	// Backoff before first retry, which is doubled for each retry.
+	// Default: 100ms
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+	// Maximum backoff. Requests are not retried, if Retry-After of
+	// response is longer. Default: 5s
+	MaxBackoff time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	// Portion of backoff, which is randomized, between 0 and 1. Default: 0
+	Jitter float64 `yaml:"jitter" json:"jitter"`
+	// Response statuses, which are retried. Default: 429, 502, 503, 504
+	Statuses []int `yaml:"statuses" json:"statuses"`
+}
+
+// CircuitBreakerPolicy defines per host circuit breaker. Circuit is opened
+// after consecutive failures and requests fail immediately, until one
+// probe request succeeds after open timeout.
+type CircuitBreakerPolicy struct {
+	// Number of consecutive failures, which opens circuit. Default:
+	// circuit breaker is disabled
+	FailureThreshold int `yaml:"failure_threshold" json:"failure_threshold"`
+	// Time circuit is open before probe request is allowed. Default: 30s
+	OpenTimeout time.Duration `yaml:"open_timeout" json:"open_timeout"`
+}
+
+// BulkheadPolicy limits number of concurrent outbound requests
+type BulkheadPolicy struct {
+	// Maximum number of concurrent requests. Default: no limit
+	MaxConcurrent int `yaml:"max_concurrent" json:"max_concurrent"`
+	// Time to wait free slot before request fails. Default: 0
+	MaxWait time.Duration `yaml:"max_wait" json:"max_wait"`
+}
+
+// Methods, which are retried without Idempotency-Key header
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// retryable tells if request can be retried
+func retryable(req *http.Request) bool {
+	if !idempotentMethods[req.Method] && req.Header.Get("Idempotency-Key") == "" {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryStatus tells if response status is retried
+func (p RetryPolicy) retryStatus(status int) bool {
+	statuses := p.Statuses
+	if statuses == nil {
+		statuses = []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		}
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns exponential backoff before given retry
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	backoff := time.Duration(math.Min(float64(initial)*math.Pow(2, float64(retry-1)), float64(p.maxBackoff())))
+	if p.Jitter > 0 {
+		backoff -= time.Duration(p.Jitter * rand.Float64() * float64(backoff))
+	}
+	return backoff
+}
+
+// maxBackoff returns maximum backoff
+func (p RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return 5 * time.Second
+	}
+	return p.MaxBackoff
+}
+
+// retryAfter returns delay of Retry-After header in seconds or HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// States of circuit breaker
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// circuitBreaker tracks failures of one host
+type circuitBreaker struct {
+	mux      sync.Mutex
+	policy   CircuitBreakerPolicy
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// transition is change of circuit breaker state. Zero value means that
+// state is not changed.
+type transition struct {
+	from string
+	to   string
+}
+
+// allow tells if request can be made. Open circuit is changed to half-open
+// after open timeout, and then one probe request is allowed.
+func (cb *circuitBreaker) allow() (transition, error) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	var tr transition
+	if cb.state == circuitOpen {
+		timeout := cb.policy.OpenTimeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		if time.Since(cb.openedAt) < timeout {
+			return tr, ErrCircuitOpen
+		}
+		tr = cb.setState(circuitHalfOpen)
+	}
+	if cb.state == circuitHalfOpen {
+		if cb.probing {
+			return tr, ErrCircuitOpen
+		}
+		cb.probing = true
+	}
+	return tr, nil
+}
+
+// record records result of request
+func (cb *circuitBreaker) record(success bool) transition {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	cb.probing = false
+	if success {
+		cb.failures = 0
+		return cb.setState(circuitClosed)
+	}
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.policy.FailureThreshold {
+		cb.openedAt = time.Now()
+		return cb.setState(circuitOpen)
+	}
+	return transition{}
+}
+
+// release ends request without recording its result, so that other
+// request can probe half-open circuit.
+func (cb *circuitBreaker) release() {
+	cb.mux.Lock()
+	cb.probing = false
+	cb.mux.Unlock()
+}
+
+// current returns current state
+func (cb *circuitBreaker) current() string {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	return cb.state
+}
+
+// setState changes state. Lock must be held.
+func (cb *circuitBreaker) setState(state string) transition {
+	if cb.state == state {
+		return transition{}
+	}
+	tr := transition{from: cb.state, to: state}
+	cb.state = state
+	return tr
+}
+
+// bulkhead limits concurrent requests
+type bulkhead struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// acquire reserves slot for request. Slot must be released after request.
+func (b *bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if b.wait <= 0 {
+		return ErrBulkheadFull
+	}
+
+	t := time.NewTimer(b.wait)
+	defer t.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-t.C:
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
+
+// circuit returns circuit breaker of host, or nil if circuit breaker is
+// disabled.
+func (t *Transport) circuit(host string) *circuitBreaker {
+	if t.conf.CircuitBreaker.FailureThreshold <= 0 {
+		return nil
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.circuits == nil {
+		t.circuits = map[string]*circuitBreaker{}
+	}
+	cb, ok := t.circuits[host]
+	if !ok {
+		cb = &circuitBreaker{policy: t.conf.CircuitBreaker, state: circuitClosed}
+		t.circuits[host] = cb
+	}
+	return cb
+}
+
+// changed logs and tags state change of circuit breaker
+func changed(tr transition, host string, logger logging.Logger, span opentracing.Span) {
+	if tr.to == "" {
+		return
+	}
+	span.SetTag("circuit_breaker.transition", tr.from+"->"+tr.to)
+	logger.AddFields(logging.Fields{
+		"circuit_host":  host,
+		"circuit_state": tr.to,
+	}).Infof("Circuit breaker changed from %s to %s", tr.from, tr.to)
+}
+
+// bulkheadBody releases slot of bulkhead, when response body is closed
+type bulkheadBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *bulkheadBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// send makes request with policies of transport. Slot of bulkhead is held
+// until response body is closed, so that reading body is limited too.
+func (t *Transport) send(base http.RoundTripper, req *http.Request, logger logging.Logger, span opentracing.Span) (*http.Response, error) {
+	if t.bulkhead == nil {
+		return t.retry(base, req, logger, span)
+	}
+
+	if err := t.bulkhead.acquire(req.Context()); err != nil {
+		span.SetTag("bulkhead.rejected", true)
+		logger.Info("Outbound request rejected by bulkhead")
+		return nil, err
+	}
+	resp, err := t.retry(base, req, logger, span)
+	if err != nil || resp == nil || resp.Body == nil {
+		t.bulkhead.release()
+		return resp, err
+	}
+	resp.Body = &bulkheadBody{ReadCloser: resp.Body, release: t.bulkhead.release}
+	return resp, nil
+}
+
+// retry makes request with retry and circuit breaker policies
+func (t *Transport) retry(base http.RoundTripper, req *http.Request, logger logging.Logger, span opentracing.Span) (*http.Response, error) {
+	ctx := req.Context()
+	host := req.URL.Host
+	cb := t.circuit(host)
+	policy := t.conf.Retry
+	attempts := policy.MaxAttempts
+	if attempts < 1 || !retryable(req) {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		if cb != nil {
+			tr, err := cb.allow()
+			changed(tr, host, logger, span)
+			span.SetTag("circuit_breaker.state", cb.current())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		r := req
+		if attempt > 1 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+		resp, err := base.RoundTrip(r)
+
+		// Cancellation or deadline of caller is not failure of host
+		if cb != nil && ctx.Err() != nil {
+			cb.release()
+		} else if cb != nil {
+			tr := cb.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+			changed(tr, host, logger, span)
+			span.SetTag("circuit_breaker.state", cb.current())
+		}
+		if attempt > 1 {
+			span.SetTag("retry.attempts", attempt)
+		}
+
+		if attempt >= attempts || (err == nil && !policy.retryStatus(resp.StatusCode)) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := policy.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp); ok {
+				if d > policy.maxBackoff() {
+					return resp, err
+				}
+				wait = d
+			}
+		}
+		// Retry is not made, if it could not complete before deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		fields := logging.Fields{
+			"retry_attempt": attempt + 1,
+			"retry_backoff": float64(wait.Nanoseconds()) / 1000000.0,
+		}
+		if err != nil {
+			fields["error.message"] = err.Error()
+		} else {
+			fields["outbound_status"] = resp.StatusCode
+		}
+		logger.AddFields(fields).Info("Retrying outbound request")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
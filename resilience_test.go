@@ -0,0 +1,356 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astota/go-logging"
+	loggertest "github.com/astota/go-logging/loggertest"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// statusResponses returns round tripper, which responds with given
+// statuses in order and records request bodies.
+func statusResponses(statuses []int, headers http.Header, bodies *[]string) roundTripperFunc {
+	var mux sync.Mutex
+	var calls int
+	return func(req *http.Request) (*http.Response, error) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		if req.Body != nil && bodies != nil {
+			b, _ := ioutil.ReadAll(req.Body)
+			*bodies = append(*bodies, string(b))
+		}
+		status := statuses[len(statuses)-1]
+		if calls < len(statuses) {
+			status = statuses[calls]
+		}
+		calls++
+		return &http.Response{
+			StatusCode: status,
+			Header:     headers,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		statuses   []int
+		retryAfter string
+		timeout    time.Duration
+		status     int
+		attempts   int
+	}{
+		{"success", http.MethodGet, nil, []int{200}, "", 0, 200, 1},
+		{"retried until success", http.MethodGet, nil, []int{503, 502, 200}, "", 0, 200, 3},
+		{"attempts exhausted", http.MethodGet, nil, []int{503}, "", 0, 503, 3},
+		{"not retried status", http.MethodGet, nil, []int{500, 200}, "", 0, 500, 1},
+		{"post is not retried", http.MethodPost, nil, []int{503, 200}, "", 0, 503, 1},
+		{"post with idempotency key", http.MethodPost, map[string]string{"Idempotency-Key": "key"}, []int{503, 200}, "", 0, 200, 2},
+		{"retry after", http.MethodGet, nil, []int{429, 200}, "0", 0, 200, 2},
+		{"too long retry after", http.MethodGet, nil, []int{429, 200}, "10", 0, 429, 1},
+		{"past deadline", http.MethodGet, nil, []int{503, 200}, "", 5 * time.Millisecond, 503, 1},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			teardownTest := setupTest(t)
+			defer teardownTest(t)
+
+			tracer := mocktracer.New()
+			opentracing.SetGlobalTracer(tracer)
+
+			headers := http.Header{}
+			if tst.retryAfter != "" {
+				headers.Set("Retry-After", tst.retryAfter)
+			}
+			var bodies []string
+			conf := NewClientConfiguration()
+			conf.Retry = RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: 10 * time.Millisecond,
+				MaxBackoff:     time.Second,
+				Jitter:         0.5,
+			}
+			client := &http.Client{Transport: NewTransport(statusResponses(tst.statuses, headers, &bodies), conf)}
+
+			ctx := context.Background()
+			if tst.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tst.timeout)
+				defer cancel()
+			}
+			req, _ := http.NewRequest(tst.method, "http://localhost/test", strings.NewReader("body"))
+			req = req.WithContext(ctx)
+			for k, v := range tst.headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %s", err.Error())
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tst.status {
+				t.Errorf("incorrect status, expected: %d, got: %d", tst.status, resp.StatusCode)
+			}
+			if len(bodies) != tst.attempts {
+				t.Fatalf("incorrect number of attempts, expected: %d, got: %d", tst.attempts, len(bodies))
+			}
+			for _, b := range bodies {
+				if b != "body" {
+					t.Errorf("body is not sent in all attempts: %v", bodies)
+				}
+			}
+			if tst.attempts > 1 {
+				if a := tracer.FinishedSpans()[0].Tag("retry.attempts"); a != tst.attempts {
+					t.Errorf("incorrect retry.attempts tag: %v", a)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, e := range expected {
+		if b := p.backoff(i + 1); b != e {
+			t.Errorf("incorrect backoff of retry %d, expected: %s, got: %s", i+1, e, b)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if b := p.backoff(1); b < 50*time.Millisecond || b > 100*time.Millisecond {
+			t.Fatalf("backoff with jitter is out of range: %s", b)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"missing", "", 0, false},
+		{"seconds", "2", 2 * time.Second, true},
+		{"past date", "Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+		{"invalid", "soon", 0, false},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			resp.Header.Set("Retry-After", tst.value)
+			d, ok := retryAfter(resp)
+			if d != tst.expected || ok != tst.ok {
+				t.Errorf("incorrect result, expected: %s %t, got: %s %t", tst.expected, tst.ok, d, ok)
+			}
+		})
+	}
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if d, ok := retryAfter(resp); !ok || d <= 0 || d > time.Minute {
+		t.Errorf("incorrect delay of future date: %s", d)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	var calls int
+	failing := true
+	conf := NewClientConfiguration()
+	conf.CircuitBreaker = CircuitBreakerPolicy{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond}
+	client := &http.Client{Transport: NewTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if failing {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}), conf)}
+
+	get := func(url string) error {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	get("http://service/test")
+	get("http://service/test")
+	if err := get("http://service/test"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("circuit is not open: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("request is made while circuit is open")
+	}
+	spans := tracer.FinishedSpans()
+	if tr := spans[1].Tag("circuit_breaker.transition"); tr != "closed->open" {
+		t.Errorf("incorrect transition tag: %v", tr)
+	}
+	if state := spans[2].Tag("circuit_breaker.state"); state != circuitOpen {
+		t.Errorf("incorrect state tag: %v", state)
+	}
+
+	// Other hosts have their own circuit
+	if err := get("http://other/test"); errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("circuit of other host is open")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	failing = false
+	if err := get("http://service/test"); err != nil {
+		t.Errorf("probe request failed: %s", err.Error())
+	}
+	spans = tracer.FinishedSpans()
+	last := spans[len(spans)-1]
+	if tr := last.Tag("circuit_breaker.transition"); tr != "half-open->closed" {
+		t.Errorf("incorrect transition tag: %v", tr)
+	}
+	output := logging.NewLogger().(*loggertest.TestLogger).TestOutput
+	if !strings.Contains(output, "Circuit breaker changed from closed to open") || !strings.Contains(output, "Circuit breaker changed from half-open to closed") {
+		t.Errorf("state changes are not logged: %s", output)
+	}
+}
+
+func TestCircuitBreakerCancelled(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	var calls int
+	conf := NewClientConfiguration()
+	conf.CircuitBreaker = CircuitBreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute}
+	client := &http.Client{Transport: NewTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}), conf)}
+
+	// Requests cancelled by caller do not open circuit of healthy host
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		req, _ := http.NewRequest(http.MethodGet, "http://service/test", nil)
+		_, err := client.Do(req.WithContext(ctx))
+		cancel()
+		if errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("circuit is opened by cancelled request")
+		}
+	}
+	if calls != 3 {
+		t.Errorf("incorrect number of requests, expected: 3, got: %d", calls)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	cb := &circuitBreaker{policy: CircuitBreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Millisecond}, state: circuitClosed}
+	if tr := cb.record(false); tr != (transition{circuitClosed, circuitOpen}) {
+		t.Errorf("incorrect transition: %+v", tr)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	if tr, err := cb.allow(); err != nil || tr != (transition{circuitOpen, circuitHalfOpen}) {
+		t.Errorf("probe is not allowed: %+v, %v", tr, err)
+	}
+	if _, err := cb.allow(); err != ErrCircuitOpen {
+		t.Errorf("second request is allowed while probing")
+	}
+	cb.release()
+	if _, err := cb.allow(); err != nil {
+		t.Errorf("probe is not allowed after cancelled probe: %v", err)
+	}
+	if tr := cb.record(false); tr != (transition{circuitHalfOpen, circuitOpen}) {
+		t.Errorf("failed probe does not open circuit: %+v", tr)
+	}
+}
+
+func TestBulkhead(t *testing.T) {
+	// Requests are made concurrently, so logging is disabled
+	logging.UseLogger("nil")
+	defer logging.UseLogger("test-logger")
+
+	tests := []struct {
+		name    string
+		maxWait time.Duration
+		err     error
+	}{
+		{"rejected", 0, ErrBulkheadFull},
+		{"wait timeout", 10 * time.Millisecond, ErrBulkheadFull},
+		{"waited", time.Second, nil},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			conf := NewClientConfiguration()
+			conf.Bulkhead = BulkheadPolicy{MaxConcurrent: 1, MaxWait: tst.maxWait}
+			client := &http.Client{Transport: NewTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("body")), Request: req}, nil
+			}), conf)}
+
+			// Slot is held while body of first response is open
+			first, err := client.Get("http://localhost/first")
+			if err != nil {
+				t.Fatalf("first request failed: %s", err.Error())
+			}
+			if tst.err == nil {
+				time.AfterFunc(20*time.Millisecond, func() { first.Body.Close() })
+			}
+
+			resp, err := client.Get("http://localhost/second")
+			if !errors.Is(err, tst.err) {
+				t.Errorf("incorrect error, expected: %v, got: %v", tst.err, err)
+			}
+			if err == nil {
+				resp.Body.Close()
+				return
+			}
+
+			// Slot is released only once, when body is closed
+			first.Body.Close()
+			first.Body.Close()
+			resp, err = client.Get("http://localhost/third")
+			if err != nil {
+				t.Fatalf("slot is not released when body is closed: %s", err.Error())
+			}
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestBulkheadFailedRequest(t *testing.T) {
+	conf := NewClientConfiguration()
+	conf.Bulkhead = BulkheadPolicy{MaxConcurrent: 1}
+	client := &http.Client{Transport: NewTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}), conf)}
+
+	// Slot is released immediately, when request fails
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get("http://localhost/test"); errors.Is(err, ErrBulkheadFull) {
+			t.Fatalf("slot is not released after failed request")
+		}
+	}
+}
@@ -3,187 +3,255 @@ package rest
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
-	"os"
-	"os/signal"
 	"runtime"
-	"syscall"
+	"sync"
 	"time"
 
 	"github.com/astota/go-logging"
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"github.com/sebest/xff"
 )
 
 type contextKey string
 
 var fcKey contextKey = "DefaultContext"
 
-// Server configuration
-var config = NewConfiguration()
+// Server configuration used by package level functions
+var (
+	configMux sync.RWMutex
+	config    = NewConfiguration()
+)
 
-// SetConfiguration sets configuration paremters to REST server
+// SetConfiguration sets configuration paremters to REST server. It is used
+// by package level functions InitRequest, Run and RunTLS. Servers created
+// with NewServer have their own configuration.
 func SetConfiguration(conf Configuration) {
+	configMux.Lock()
 	config = conf
+	configMux.Unlock()
+}
+
+// getConfiguration returns copy of package level configuration
+func getConfiguration() Configuration {
+	configMux.RLock()
+	defer configMux.RUnlock()
+	return config
 }
 
-// DefaultContext contains Request specific information
+// DefaultContext contains Request specific information. InitRequest
+// stores it in request Store, where other values can be added.
 type DefaultContext struct {
 	RequestID      string
 	ForwardedFor   string
 	OrganizationID string
+	// Identity of verified client certificate
+	ClientIdentity ClientIdentity
+	// Client address, scheme and host resolved from trusted forwarding
+	// headers
+	ClientIP net.IP
+	Scheme   string
+	Host     string
+	// Attributes of request mappings
+	Attributes map[string]string
+	// Effective deadline of request
+	Deadline time.Time
+
+	// Span tags of request mappings
+	tags map[string]string
 }
 
 // InitRequest initializes special variables that we want to use in request
 // Handling maximum body size, adds logger, which is attached to request
-// and adds timeout handling.
+// and adds timeout handling. Configuration set by SetConfiguration is used.
 func InitRequest(h http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Body != nil {
-			defer r.Body.Close()
-			r.Body = http.MaxBytesReader(w, r.Body, config.MaximumBodySize)
-			defer r.Body.Close()
-		}
-
-		// Extract or generate transaction id
-		requestID := ""
-		if requestID = r.Header.Get("BMG-Request-Id"); requestID == "" {
-			requestID = uuid.New().String()
-		}
+	return newServer(getConfiguration()).InitRequest(h)
+}
 
-		userIP := ""
-		if sip, _, err := net.SplitHostPort(xff.GetRemoteAddr(r)); err == nil {
-			userIP = sip
-		}
+// InitRequest initializes request like package level InitRequest, but
+// uses server configuration. It panics, if patterns of request mappings
+// or trusted proxies are invalid.
+func (s *Server) InitRequest(h http.Handler) http.HandlerFunc {
+	return s.initRequest(s.conf.Timeout.Enforce)(h).ServeHTTP
+}
 
-		logger := logging.NewLogger().AddFields(logging.Fields{
-			"request_id":  requestID,
-			"server_name": r.Host,
-			"progname":    config.ApplicationName,
-			"user_agent":  r.Header.Get("User-Agent"),
-			"user_ip":     userIP,
-		})
+// initRequest returns request initialization as middleware, which is shared
+// by InitRequest and middleware of frameworks. Timeouts are enforced only
+// if handlers can be run after timeout response.
+func (s *Server) initRequest(enforceTimeout bool) func(http.Handler) http.Handler {
+	mappings := s.conf.RequestMappings
+	if mappings == nil {
+		mappings = defaultRequestMappings()
+	}
+	rd := newRedactor(s.conf.Redaction)
+	mapper, err := newRequestMapper(mappings, rd)
+	if err != nil {
+		panic(err.Error())
+	}
+	trusted, err := parseCIDRs(s.conf.TrustedProxies)
+	if err != nil {
+		panic(fmt.Sprintf("invalid trusted proxies: %s", err.Error()))
+	}
 
-		if apiKey := r.Header.Get("BMG-Retailer-Api-Key"); apiKey != "" {
-			logger = logger.AddFields(logging.Fields{
-				"retailer_api_key": apiKey,
-			})
-		}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				defer r.Body.Close()
+			}
 
-		if apiKey := r.Header.Get("BMG-Api-Key"); apiKey != "" {
-			logger = logger.AddFields(logging.Fields{
-				"api_key": apiKey,
-			})
-		}
+			// Extract or generate transaction id and return it to caller
+			requestID := s.requestID(r)
+			if h := s.conf.RequestIDResponseHeader; h != "" {
+				w.Header().Set(h, requestID)
+			}
 
-		if authToken := r.Header.Get("BMG-Auth-Token"); authToken != "" {
-			logger = logger.AddFields(logging.Fields{
-				"auth_token": authToken,
-			})
-		}
+			client := requestClient(r, trusted)
+			fields := logging.Fields{
+				"request_id":  requestID,
+				"server_name": r.Host,
+				"progname":    s.conf.ApplicationName,
+				"user_agent":  r.Header.Get("User-Agent"),
+				"user_ip":     client.ipString(),
+			}
 
-		var organizationID string
-		if oid := r.Header.Get("BMG-Organization-Id"); oid != "" {
-			logger = logger.AddFields(logging.Fields{
-				"organization_id": oid,
+			clientID := clientIdentity(r)
+			if clientID.CommonName != "" {
+				fields["client_cn"] = clientID.CommonName
+			}
+			if clientID.SPIFFEID != "" {
+				fields["client_spiffe_id"] = clientID.SPIFFEID
+			}
+			logger := logging.NewLogger().AddFields(rd.logFields(fields))
+
+			// Mapped fields and tags are already redacted
+			mapped, err := mapper.values(r)
+			if err != nil {
+				logger.Infof("Request rejected: %s", err.Error())
+				writeError(w, http.StatusBadRequest, errorResponse{Error: err.Error(), RequestID: requestID})
+				return
+			}
+			logger = logger.AddFields(mapped.fields)
+
+			// Setup context and also add timeout, which can be shortened by
+			// client.
+			ctx, cancel := context.WithTimeout(r.Context(), s.requestTimeout(r))
+			defer cancel()
+			deadline, _ := ctx.Deadline()
+
+			store := newStore(DefaultContext{
+				RequestID:      requestID,
+				ForwardedFor:   client.forwardedFor,
+				OrganizationID: mapped.attributes[OrganizationIDAttribute],
+				ClientIdentity: clientID,
+				ClientIP:       client.ip,
+				Scheme:         client.scheme,
+				Host:           client.host,
+				Attributes:     mapped.attributes,
+				Deadline:       deadline,
+				tags:           mapped.tags,
 			})
-			organizationID = oid
-		}
-
-		// Setup context and also add timeout
-		ctx, cancel := context.WithTimeout(r.Context(), config.MaximumRequestDuration)
-		defer cancel()
+			ctx = context.WithValue(ctx, fcKey, store)
+			ctx = withRedactor(ctx, rd)
+			ctx = logging.SetLogger(ctx, logger)
+			r = r.WithContext(ctx)
+
+			// Limit of body can be changed by middleware before body is read.
+			var decompressed io.Closer
+			if r.Body != nil {
+				limit, ok := bodyLimit(s.conf.BodyLimits, r)
+				if !ok {
+					limit = s.conf.MaximumBodySize
+				}
+
+				// Limit is applied to both compressed and decompressed body
+				var compressed *bodyLimiter
+				if s.conf.DecompressRequests {
+					compressed = newBodyLimiter(r, limit)
+					if err := decompressBody(r, compressed); err != nil {
+						logger.Infof("Request rejected: %s", err.Error())
+						w.Header().Set("Accept-Encoding", supportedEncodings)
+						writeError(w, http.StatusUnsupportedMediaType, errorResponse{Error: err.Error(), RequestID: requestID})
+						return
+					}
+					decompressed = r.Body
+				}
+
+				store.body = newBodyLimiter(r, limit)
+				store.body.compressed = compressed
+				r.Body = store.body
+			}
 
-		ctx = context.WithValue(ctx, fcKey, DefaultContext{
-			RequestID:      requestID,
-			ForwardedFor:   r.Header.Get("X-Forwarded-For"),
-			OrganizationID: organizationID,
+			// call original handler
+			if enforceTimeout {
+				// Handler can read body after timeout response, so
+				// decoders are closed by handler goroutine
+				s.serveWithTimeout(h, w, r, requestID, decompressed)
+				return
+			}
+			if decompressed != nil {
+				defer decompressed.Close()
+			}
+			h.ServeHTTP(w, r)
 		})
+	}
+}
 
-		ctx = logging.SetLogger(ctx, logger)
-		r = r.WithContext(ctx)
-
-		// call original handler
-		h.ServeHTTP(w, r)
+// Remaining returns time remaining until deadline of request. Zero is
+// returned, if deadline is not defined.
+func (fctx DefaultContext) Remaining() time.Duration {
+	if fctx.Deadline.IsZero() {
+		return 0
 	}
+	return time.Until(fctx.Deadline)
 }
 
 func setDefaultContext(ctx context.Context, fctx DefaultContext) context.Context {
 	return context.WithValue(ctx, fcKey, fctx)
 }
 
-// GetDefaultContext tries to get request specific data from context
+// GetDefaultContext tries to get request specific data from context.
+// Copy of default context of request store is returned, so changes made
+// by Store.Update are visible after update.
 func GetDefaultContext(ctx context.Context) (DefaultContext, error) {
 	val := ctx.Value(fcKey)
 	if val == nil {
 		return DefaultContext{}, fmt.Errorf("no DefaultContext")
 	}
 
-	if fctx, ok := val.(DefaultContext); ok {
+	switch fctx := val.(type) {
+	case *Store:
+		return fctx.DefaultContext(), nil
+	case DefaultContext:
 		return fctx, nil
 	}
 
 	return DefaultContext{}, fmt.Errorf("default context key is corrupted")
 }
 
-// Shutdown will shutdown server gracefully when SIGTERM or SIGINT is received
-func shutdown(s *http.Server) {
-	// Handle SIGINT and SIGTERM
-	sig := make(chan os.Signal)
-	signal.Notify(sig, syscall.SIGINT)
-	signal.Notify(sig, syscall.SIGTERM)
-
-	<-sig
-	logger := logging.NewLogger()
-	logger.Info("Shutting down server...")
-
-	// Time which is waited before forcefully shutdown server.
-	// Kubernetes default between SIGTERM and SIGKILL
-	// is 30s, so shorter time should be configured.
-	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownGraceTime)
-	defer cancel()
-	if err := s.Shutdown(ctx); err != nil {
-		logger.Fatal("Could not shutdown gracefully")
-	}
-}
-
 // Run starts new server instance and also listen SIGINT and SIGTERM signals
 // to gracefully stop server procss.
 func Run(s *http.Server) {
-	logger := logging.NewLogger()
-
-	// Make custom initializations to request
-	s.Handler = InitRequest(s.Handler)
-
-	go shutdown(s)
-
-	// Start server
-	if err := s.ListenAndServe(); err != http.ErrServerClosed {
-		logger.Fatal(fmt.Sprintf("Server error: %s", err.Error()))
-	}
-
-	logger.Info("Server gracefully stopped")
+	NewServer(getConfiguration(), WithHTTPServer(s)).Run()
 }
 
 // RunTLS starts new server TLS instance and also listen SIGINT and
 // SIGTERM signals to gracefully stop server procss.
 func RunTLS(s *http.Server) {
-	logger := logging.NewLogger()
-
-	// Make custom initializations to request
-	s.Handler = InitRequest(s.Handler)
-
-	go shutdown(s)
+	NewServer(getConfiguration(), WithHTTPServer(s)).RunTLS()
+}
 
-	// Start server
-	if err := s.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
-		logger.Fatal(fmt.Sprintf("Server error: %s", err.Error()))
-	}
+// RunContext starts new server instance and stops it gracefully, when
+// context is cancelled. Listen and shutdown errors are returned. Signals
+// are not handled, SignalContext can be used for that.
+func RunContext(ctx context.Context, s *http.Server) error {
+	return NewServer(getConfiguration(), WithHTTPServer(s)).RunContext(ctx)
+}
 
-	logger.Info("Server gracefully stopped")
+// RunTLSContext starts new TLS server instance like RunContext.
+func RunTLSContext(ctx context.Context, s *http.Server) error {
+	return NewServer(getConfiguration(), WithHTTPServer(s)).RunTLSContext(ctx)
 }
 
 // RequestLogger return request logger handler. This will call gin Next()
@@ -192,10 +260,12 @@ func RunTLS(s *http.Server) {
 func RequestLogger(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		req := c.Request()
-		logger := logging.GetLogger(req.Context()).AddFields(logging.Fields{
-			"method": req.Method,
-			"path":   req.URL.Path,
-		})
+		rd := getRedactor(req.Context())
+		logger := logging.GetLogger(req.Context()).AddFields(rd.logFields(logging.Fields{
+			"method":   req.Method,
+			"path":     req.URL.Path,
+			"protocol": req.Proto,
+		}))
 		logger.Info("Starting")
 		t := time.Now()
 
@@ -206,11 +276,18 @@ func RequestLogger(next echo.HandlerFunc) echo.HandlerFunc {
 			status = httpError.Code
 		}
 
-		// log request
-		logger.AddFields(logging.Fields{
+		fields := logging.Fields{
 			"status":       status,
 			"elapsed_time": float64(time.Since(t).Nanoseconds()) / 1000000.0,
-		}).Info("Finished")
+		}
+		// Client has received timeout response instead of response of handler
+		if timeoutStatus, ok := getStore(req.Context()).timedOut(); ok {
+			fields["status"] = timeoutStatus
+			fields["timeout"] = true
+		}
+
+		// log request
+		logger.AddFields(rd.logFields(fields)).Info("Finished")
 
 		return err
 	}
@@ -223,12 +300,23 @@ func Recovery(next echo.HandlerFunc) echo.HandlerFunc {
 			if r := recover(); r != nil {
 				st := make([]byte, 1<<15)
 				runtime.Stack(st, false)
-				httprequest, _ := httputil.DumpRequest(c.Request(), false)
-				logger := logging.GetLogger(c.Request().Context())
-				logger = logger.AddFields(logging.Fields{
+				// Secrets of headers are redacted and query is removed
+				// from request dump
+				req := *c.Request()
+				rd := getRedactor(req.Context())
+				req.Header = rd.header(req.Header)
+				u := *req.URL
+				u.User = nil
+				u.RawQuery = ""
+				u.ForceQuery = false
+				req.URL = &u
+				req.RequestURI = ""
+				httprequest, _ := httputil.DumpRequest(&req, false)
+				logger := logging.GetLogger(req.Context())
+				logger = logger.AddFields(rd.logFields(logging.Fields{
 					"stacktrace": string(st),
 					"request":    string(httprequest),
-				})
+				}))
 				logger.Error("internal server error")
 				c.String(http.StatusInternalServerError, "")
 			}
@@ -89,6 +89,10 @@ func TestRequestLogger(t *testing.T) {
 			if _, exists := l.Fields["path"]; !exists {
 				t.Errorf("path is not field in logger")
 			}
+
+			if proto, _ := l.Fields["protocol"]; proto != "HTTP/1.1" {
+				t.Errorf("incorrect protocol, expected: '%s', got: '%v'", "HTTP/1.1", proto)
+			}
 		})
 	}
 }
@@ -161,17 +165,18 @@ func TestInitRequest(t *testing.T) {
 					if name, ok := l.Fields["user_agent"]; !ok || name != tst.headers["User-Agent"] {
 						t.Errorf("invalid user_agent")
 					}
-					if name, _ := l.Fields["api_key"]; name != tst.headers["BMG-Api-Key"] && name != nil {
+					// Keys and tokens are masked by default
+					if name, _ := l.Fields["api_key"]; name != maskValue(tst.headers["BMG-Api-Key"], 4) && name != nil {
 						t.Errorf("invalid api_key")
 					}
-					if name, _ := l.Fields["retailer_api_key"]; name != tst.headers["BMG-Retailer-Api-Key"] && name != nil {
+					if name, _ := l.Fields["retailer_api_key"]; name != maskValue(tst.headers["BMG-Retailer-Api-Key"], 4) && name != nil {
 						t.Errorf("invalid retailer_api_key")
 					}
 					if ip, ok := l.Fields["user_ip"]; !ok || ip != tst.ip {
 						t.Errorf("invalid user_ip")
 					}
 					atoken, aexists := tst.headers["BMG-Auth-Token"]
-					if token, ok := l.Fields["auth_token"]; (ok || aexists) && token != atoken {
+					if token, ok := l.Fields["auth_token"]; (ok || aexists) && token != maskValue(atoken, 4) {
 						t.Errorf("invalid auth token")
 					}
 					rorg, texists := tst.headers["BMG-Organization-Id"]
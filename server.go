@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/astota/go-logging"
+	"github.com/labstack/echo/v4"
+)
+
+// Server is REST server instance. It owns echo router, HTTP server,
+// middleware stack and shutdown handling. Each server has its own
+// configuration, so multiple servers can be run in same process.
+type Server struct {
+	conf        Configuration
+	echo        *echo.Echo
+	server      *http.Server
+	handler     http.Handler
+	addr        string
+	middleware  []echo.MiddlewareFunc
+	listeners   []Listener
+	hooks       []Hook
+	idGenerator RequestIDGenerator
+
+	restartSignals []os.Signal
+	rejected       uint64
+	registerOnce   sync.Once
+	claimOnce      sync.Once
+	startOnce      sync.Once
+
+	stateMux   sync.Mutex
+	ready      bool
+	drainStart time.Time
+	running    []*listener
+	stop       context.CancelFunc
+}
+
+// Option is functional option to configure Server
+type Option func(*Server)
+
+// WithEcho sets echo instance, which is used to route requests.
+// By default new echo instance is created.
+func WithEcho(e *echo.Echo) Option {
+	return func(s *Server) {
+		s.echo = e
+	}
+}
+
+// WithHTTPServer sets HTTP server, which is used to serve requests.
+// If server has handler defined, it is used instead of echo router.
+func WithHTTPServer(hs *http.Server) Option {
+	return func(s *Server) {
+		s.server = hs
+	}
+}
+
+// WithAddress sets address where server listens. Example ":8080" or
+// "unix:///var/run/app.sock" for Unix domain socket.
+func WithAddress(addr string) Option {
+	return func(s *Server) {
+		s.addr = addr
+	}
+}
+
+// WithMiddleware adds echo middleware to server middleware stack.
+// Middleware are used in given order.
+func WithMiddleware(m ...echo.MiddlewareFunc) Option {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, m...)
+	}
+}
+
+// newServer creates server instance without router, this is enough
+// to handle request initialization.
+func newServer(conf Configuration) *Server {
+	return &Server{
+		conf:        conf,
+		server:      &http.Server{},
+		idGenerator: UUIDv4Generator{},
+	}
+}
+
+// NewServer creates new server instance using given configuration and
+// options.
+func NewServer(conf Configuration, opts ...Option) *Server {
+	s := newServer(conf)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.addr != "" {
+		s.server.Addr = s.addr
+	}
+	if s.echo == nil {
+		s.echo = echo.New()
+	}
+	s.handler = s.server.Handler
+	if s.handler == nil {
+		s.handler = s.echo
+	}
+	// Structured 413 response is returned, when body limit is exceeded
+	s.echo.Use(BodyLimits())
+	s.echo.Use(s.middleware...)
+
+	return s
+}
+
+// Configuration returns server configuration
+func (s *Server) Configuration() Configuration {
+	return s.conf
+}
+
+// Echo returns echo router, which can be used to add routes to server
+func (s *Server) Echo() *echo.Echo {
+	return s.echo
+}
+
+// HTTPServer returns HTTP server used to serve requests
+func (s *Server) HTTPServer() *http.Server {
+	return s.server
+}
+
+// Handler returns server handler with request initialization
+func (s *Server) Handler() http.Handler {
+	return s.InitRequest(s.handler)
+}
+
+// RunContext starts server and all its listeners and blocks until given
+// context is cancelled or one of listeners fails. Then all listeners are
+// shutdown gracefully within ShutdownGraceTime. Listen and shutdown errors
+// are returned.
+func (s *Server) RunContext(ctx context.Context) error {
+	return s.run(ctx, false)
+}
+
+// RunTLSContext starts server like RunContext, but default listener uses
+// TLS. Certificates must be defined in server TLSConfig. Other listeners
+// use their own TLS configuration.
+func (s *Server) RunTLSContext(ctx context.Context) error {
+	return s.run(ctx, true)
+}
+
+// Run starts server and also listen SIGINT and SIGTERM signals
+// to gracefully stop server procss.
+func (s *Server) Run() {
+	s.runWithSignals(s.RunContext)
+}
+
+// RunTLS starts TLS server and also listen SIGINT and SIGTERM signals
+// to gracefully stop server procss.
+func (s *Server) RunTLS() {
+	s.runWithSignals(s.RunTLSContext)
+}
+
+func (s *Server) runWithSignals(run func(context.Context) error) {
+	ctx, cancel := SignalContext(context.Background())
+	defer cancel()
+
+	if err := run(ctx); err != nil {
+		logging.NewLogger().Fatal(err.Error())
+	}
+}
@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestNewServer(t *testing.T) {
+	e := echo.New()
+	hs := &http.Server{}
+	var called bool
+	mw := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			called = true
+			return next(c)
+		}
+	}
+
+	conf := NewConfiguration()
+	conf.ApplicationName = "server_test"
+	s := NewServer(conf, WithAddress(":8080"), WithEcho(e), WithHTTPServer(hs), WithMiddleware(mw))
+
+	if s.Echo() != e {
+		t.Errorf("echo instance is not used")
+	}
+	if s.HTTPServer() != hs {
+		t.Errorf("http server instance is not used")
+	}
+	if hs.Addr != ":8080" {
+		t.Errorf("incorrect address, expected: '%s', got: '%s'", ":8080", hs.Addr)
+	}
+	if s.Configuration().ApplicationName != conf.ApplicationName {
+		t.Errorf("incorrect configuration")
+	}
+
+	e.GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, "")
+	})
+	resp := httptest.NewRecorder()
+	s.Handler().ServeHTTP(resp, createRequest(http.MethodGet))
+	if !called {
+		t.Errorf("middleware is not called")
+	}
+	if resp.Code != http.StatusOK {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusOK, resp.Code)
+	}
+}
+
+func TestServerHTTPHandler(t *testing.T) {
+	hs := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}),
+	}
+	s := NewServer(NewConfiguration(), WithHTTPServer(hs))
+
+	resp := httptest.NewRecorder()
+	s.Handler().ServeHTTP(resp, createRequest(http.MethodGet))
+	if resp.Code != http.StatusAccepted {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusAccepted, resp.Code)
+	}
+}
+
+func TestServerConfigurationIsolation(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tests := []struct {
+		name    string
+		maxBody int64
+		err     bool
+	}{
+		{"small limit", 5, true},
+		{"large limit", 1 << 20, false},
+	}
+
+	handlers := make([]http.Handler, len(tests))
+	for i, tst := range tests {
+		conf := NewConfiguration()
+		conf.MaximumBodySize = tst.maxBody
+		s := NewServer(conf)
+		s.Echo().POST("/test", func(c echo.Context) error {
+			if _, err := ioutil.ReadAll(c.Request().Body); err != nil {
+				return c.String(http.StatusRequestEntityTooLarge, "")
+			}
+			return c.String(http.StatusOK, "")
+		})
+		handlers[i] = s.Handler()
+	}
+
+	// Both servers are created before requests, so configuration
+	// of later server must not affect earlier one.
+	for i, tst := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader("long request body"))
+		resp := httptest.NewRecorder()
+		handlers[i].ServeHTTP(resp, req)
+		if (resp.Code == http.StatusRequestEntityTooLarge) != tst.err {
+			t.Errorf("%s: unexpected status %d", tst.name, resp.Code)
+		}
+	}
+}
+
+func TestServerRunContext(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	addr := freeAddress(t)
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	s := NewServer(conf, WithAddress(addr))
+	s.Echo().GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+
+	if resp, err := getWithRetry("http://" + addr + "/test"); err != nil {
+		t.Errorf("request failed: %s", err.Error())
+	} else if resp.StatusCode != http.StatusOK {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("server is not stopped")
+	}
+}
+
+func TestServerRunContextErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		tls  bool
+	}{
+		{"invalid address", "invalid:address:1", false},
+		{"tls without certificates", freeAddress(t), true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			s := NewServer(NewConfiguration(), WithAddress(tst.addr))
+
+			var err error
+			if tst.tls {
+				err = s.RunTLSContext(context.Background())
+			} else {
+				err = s.RunContext(context.Background())
+			}
+			if err == nil {
+				t.Errorf("error is not returned")
+			}
+		})
+	}
+}
+
+// freeAddress returns local address, which is free to listen
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// testClient does not keep connections open, so that those do not
+// delay graceful shutdown of test servers.
+var testClient = &http.Client{
+	Transport: &http.Transport{DisableKeepAlives: true},
+}
+
+// getWithRetry makes GET request and retries it until server is listening
+func getWithRetry(url string) (*http.Response, error) {
+	var err error
+	for i := 0; i < 50; i++ {
+		var resp *http.Response
+		if resp, err = testClient.Get(url); err == nil {
+			resp.Body.Close()
+			return resp, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil, err
+}
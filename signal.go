@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/astota/go-logging"
+)
+
+// SignalContext returns context, which is cancelled when one of given
+// signals is received. If signals are not given, SIGINT and SIGTERM are
+// used. Cancel function should be called to release resources.
+func SignalContext(parent context.Context, sig ...os.Signal) (context.Context, context.CancelFunc) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+
+	go func() {
+		defer signal.Stop(ch)
+		select {
+		case s := <-ch:
+			logging.NewLogger().Infof("Received signal %s", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
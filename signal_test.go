@@ -0,0 +1,36 @@
+package rest
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalContext(t *testing.T) {
+	ctx, cancel := SignalContext(context.Background(), syscall.SIGUSR1)
+	defer cancel()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("cannot send signal: %s", err.Error())
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("context is not cancelled after signal")
+	}
+}
+
+func TestSignalContextCancel(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := SignalContext(parent)
+	defer cancel()
+
+	cancelParent()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("context is not cancelled with parent")
+	}
+}
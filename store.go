@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Key identifies value in request store. Keys are compared by identity,
+// so those should be created once, example as package level variables.
+type Key struct {
+	name string
+	typ  reflect.Type
+}
+
+// NewKey creates key of values, which have same type as zero. If zero is
+// nil, values of any type are accepted.
+//
+//	var UserIDKey = rest.NewKey("user_id", "")
+//	var RolesKey = rest.NewKey("roles", []string(nil))
+func NewKey(name string, zero interface{}) *Key {
+	return &Key{
+		name: name,
+		typ:  reflect.TypeOf(zero),
+	}
+}
+
+// String returns name of key
+func (k *Key) String() string {
+	return k.name
+}
+
+// Store is request specific store, which is created by InitRequest. Values
+// can be added and changed safely by handlers and middleware during
+// request. Fields of DefaultContext are available as accessors.
+type Store struct {
+	mux    sync.RWMutex
+	fctx   DefaultContext
+	values map[*Key]interface{}
+
+	// Internal state of request
+	timeoutStatus int
+	body          *bodyLimiter
+}
+
+// newStore creates store with given default context
+func newStore(fctx DefaultContext) *Store {
+	return &Store{
+		fctx:   fctx,
+		values: map[*Key]interface{}{},
+	}
+}
+
+// GetStore returns request store of context. Error is returned, if
+// request is not initialized by InitRequest.
+func GetStore(ctx context.Context) (*Store, error) {
+	if store := getStore(ctx); store != nil {
+		return store, nil
+	}
+	return nil, errors.New("no request store")
+}
+
+// getStore returns request store of context or nil
+func getStore(ctx context.Context) *Store {
+	store, _ := ctx.Value(fcKey).(*Store)
+	return store
+}
+
+// Set sets value of key. Error is returned, if type of value does not
+// match type of key.
+func (s *Store) Set(key *Key, value interface{}) error {
+	if key.typ != nil && reflect.TypeOf(value) != key.typ {
+		return fmt.Errorf("invalid type %T of %s, expected %s", value, key.name, key.typ)
+	}
+
+	s.mux.Lock()
+	s.values[key] = value
+	s.mux.Unlock()
+	return nil
+}
+
+// Get returns value of key and tells if it is defined
+func (s *Store) Get(key *Key) (interface{}, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Delete removes value of key
+func (s *Store) Delete(key *Key) {
+	s.mux.Lock()
+	delete(s.values, key)
+	s.mux.Unlock()
+}
+
+// DefaultContext returns copy of default context
+func (s *Store) DefaultContext() DefaultContext {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	fctx := s.fctx
+	if s.fctx.Attributes != nil {
+		fctx.Attributes = make(map[string]string, len(s.fctx.Attributes))
+		for k, v := range s.fctx.Attributes {
+			fctx.Attributes[k] = v
+		}
+	}
+	return fctx
+}
+
+// Update changes fields of default context. Changes are visible to
+// GetDefaultContext after update.
+func (s *Store) Update(f func(*DefaultContext)) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// Attributes may be shared with earlier copies, so those are copied
+	// before change.
+	attributes := make(map[string]string, len(s.fctx.Attributes))
+	for k, v := range s.fctx.Attributes {
+		attributes[k] = v
+	}
+	s.fctx.Attributes = attributes
+	f(&s.fctx)
+}
+
+// RequestID returns request ID
+func (s *Store) RequestID() string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.RequestID
+}
+
+// ForwardedFor returns forwarded for addresses of request
+func (s *Store) ForwardedFor() string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.ForwardedFor
+}
+
+// OrganizationID returns organization ID
+func (s *Store) OrganizationID() string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.OrganizationID
+}
+
+// ClientIdentity returns identity of verified client certificate
+func (s *Store) ClientIdentity() ClientIdentity {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.ClientIdentity
+}
+
+// ClientIP returns resolved client address
+func (s *Store) ClientIP() net.IP {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.ClientIP
+}
+
+// Scheme returns resolved scheme of request
+func (s *Store) Scheme() string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.Scheme
+}
+
+// Host returns resolved host of request
+func (s *Store) Host() string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.Host
+}
+
+// Attribute returns attribute of request mappings
+func (s *Store) Attribute(name string) string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.Attributes[name]
+}
+
+// Deadline returns effective deadline of request
+func (s *Store) Deadline() time.Time {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.fctx.Deadline
+}
+
+// setTimedOut marks request timed out with given response status
+func (s *Store) setTimedOut(status int) {
+	s.mux.Lock()
+	s.timeoutStatus = status
+	s.mux.Unlock()
+}
+
+// timedOut returns status of timeout response and true, if request has
+// timed out.
+func (s *Store) timedOut() (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.timeoutStatus, s.timeoutStatus != 0
+}
@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	testUserIDKey = NewKey("user_id", "")
+	testRolesKey  = NewKey("roles", []string(nil))
+	testAnyKey    = NewKey("any", nil)
+)
+
+func TestStoreSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   *Key
+		value interface{}
+		err   bool
+	}{
+		{"string", testUserIDKey, "user-1", false},
+		{"slice", testRolesKey, []string{"admin"}, false},
+		{"invalid type", testUserIDKey, 1, true},
+		{"invalid slice type", testRolesKey, "admin", true},
+		{"any type", testAnyKey, 1.5, false},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			store := newStore(DefaultContext{})
+			err := store.Set(tst.key, tst.value)
+			if (err != nil) != tst.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			value, ok := store.Get(tst.key)
+			if ok == tst.err {
+				t.Fatalf("incorrect existence of value: %t", ok)
+			}
+			if ok && !reflect.DeepEqual(value, tst.value) {
+				t.Errorf("incorrect value, expected: %v, got: %v", tst.value, value)
+			}
+		})
+	}
+}
+
+func TestStoreKeysAreIdentities(t *testing.T) {
+	store := newStore(DefaultContext{})
+	store.Set(testUserIDKey, "user-1")
+
+	if _, ok := store.Get(NewKey("user_id", "")); ok {
+		t.Errorf("key with same name returns value")
+	}
+	store.Delete(testUserIDKey)
+	if _, ok := store.Get(testUserIDKey); ok {
+		t.Errorf("value is not deleted")
+	}
+	if testUserIDKey.String() != "user_id" {
+		t.Errorf("incorrect key name: %s", testUserIDKey)
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	store := newStore(DefaultContext{
+		RequestID:  "request-1",
+		Attributes: map[string]string{"tenant": "acme"},
+	})
+	ctx := context.WithValue(context.Background(), fcKey, store)
+
+	before, _ := GetDefaultContext(ctx)
+	store.Update(func(fctx *DefaultContext) {
+		fctx.OrganizationID = "1000"
+		fctx.Attributes["tenant"] = "other"
+	})
+	after, err := GetDefaultContext(ctx)
+	if err != nil {
+		t.Fatalf("default context is not returned: %s", err.Error())
+	}
+
+	if after.OrganizationID != "1000" || store.OrganizationID() != "1000" || after.RequestID != "request-1" {
+		t.Errorf("update is not visible: %+v", after)
+	}
+	if before.Attributes["tenant"] != "acme" || before.OrganizationID != "" {
+		t.Errorf("earlier copy is changed: %+v", before)
+	}
+	if store.Attribute("tenant") != "other" {
+		t.Errorf("attribute is not updated")
+	}
+
+	// Returned copy can not change store
+	after.Attributes["tenant"] = "changed"
+	if store.Attribute("tenant") != "other" {
+		t.Errorf("store is changed through copy")
+	}
+}
+
+func TestGetStore(t *testing.T) {
+	if _, err := GetStore(context.Background()); err == nil {
+		t.Errorf("error is not returned without store")
+	}
+	if _, err := GetStore(setDefaultContext(context.Background(), DefaultContext{})); err == nil {
+		t.Errorf("error is not returned for plain default context")
+	}
+}
+
+func TestStoreInRequest(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	s := NewServer(NewConfiguration())
+	// Authentication middleware adds user after request is initialized
+	s.Echo().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			store, err := GetStore(c.Request().Context())
+			if err != nil {
+				return err
+			}
+			store.Set(testUserIDKey, "user-1")
+			store.Set(testRolesKey, []string{"admin"})
+			store.Update(func(fctx *DefaultContext) {
+				fctx.OrganizationID = "1000"
+			})
+			return next(c)
+		}
+	})
+
+	var userID, organizationID, requestID string
+	var roles []string
+	s.Echo().GET("/test", func(c echo.Context) error {
+		store, _ := GetStore(c.Request().Context())
+		v, _ := store.Get(testUserIDKey)
+		userID, _ = v.(string)
+		v, _ = store.Get(testRolesKey)
+		roles, _ = v.([]string)
+		requestID = store.RequestID()
+		fctx, _ := GetDefaultContext(c.Request().Context())
+		organizationID = fctx.OrganizationID
+		return c.String(http.StatusOK, "")
+	})
+
+	s.Handler().ServeHTTP(httptest.NewRecorder(), createRequest(http.MethodGet, addHeader("BMG-Request-Id", "request-1")))
+
+	if userID != "user-1" || !reflect.DeepEqual(roles, []string{"admin"}) {
+		t.Errorf("values of middleware are not available: %s, %v", userID, roles)
+	}
+	if organizationID != "1000" || requestID != "request-1" {
+		t.Errorf("incorrect default context: %s, %s", organizationID, requestID)
+	}
+}
+
+func TestStoreConcurrentAccess(t *testing.T) {
+	store := newStore(DefaultContext{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.Set(testAnyKey, i)
+			store.Get(testAnyKey)
+			store.Update(func(fctx *DefaultContext) {
+				fctx.Attributes["key"] = "value"
+			})
+			store.DefaultContext()
+		}(i)
+	}
+	wg.Wait()
+}
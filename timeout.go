@@ -0,0 +1,188 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/astota/go-logging"
+)
+
+// TimeoutConfiguration defines how request timeouts are enforced
+type TimeoutConfiguration struct {
+	// Respond with timeout response, when deadline of request is passed,
+	// even if handler has not returned. Later writes of handler are
+	// discarded. Handlers cannot hijack connections, when enabled.
+	// Default: false
+	Enforce bool `yaml:"enforce" json:"enforce"`
+	// Status of timeout response. Default: 504
+	Status int `yaml:"status" json:"status"`
+	// Error message of timeout response. Default: request timeout
+	Message string `yaml:"message" json:"message"`
+}
+
+// serveWithTimeout runs handler and writes timeout response, if deadline
+// of request passes before handler returns. Panics of handler are passed
+// to caller, or logged if caller has already returned. Response is aborted
+// with http.ErrAbortHandler, if it was started before timeout. Body is
+// closed, when handler returns, if it is defined.
+func (s *Server) serveWithTimeout(h http.Handler, w http.ResponseWriter, r *http.Request, requestID string, body io.Closer) {
+	ctx := r.Context()
+	tw := &timeoutWriter{w: w, h: http.Header{}}
+	done := make(chan struct{})
+	panics := make(chan interface{}, 1)
+	var mux sync.Mutex
+	returned := false
+
+	go func() {
+		if body != nil {
+			defer body.Close()
+		}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			if !returned {
+				panics <- p
+				return
+			}
+
+			st := make([]byte, 1<<15)
+			st = st[:runtime.Stack(st, false)]
+			logging.GetLogger(ctx).AddFields(logging.Fields{
+				"stacktrace": string(st),
+			}).Errorf("Panic after request timed out: %v", p)
+		}()
+		h.ServeHTTP(tw, r)
+		close(done)
+	}()
+
+	select {
+	case p := <-panics:
+		panic(p)
+	case <-done:
+	case <-ctx.Done():
+		// Later panics of handler are logged
+		mux.Lock()
+		returned = true
+		mux.Unlock()
+		select {
+		case p := <-panics:
+			panic(p)
+		default:
+		}
+
+		if ctx.Err() != context.DeadlineExceeded {
+			// Client is gone, so there is no one to respond
+			tw.discard()
+			return
+		}
+
+		status := s.conf.Timeout.Status
+		if status == 0 {
+			status = http.StatusGatewayTimeout
+		}
+		message := s.conf.Timeout.Message
+		if message == "" {
+			message = "request timeout"
+		}
+		getStore(ctx).setTimedOut(status)
+
+		logger := logging.GetLogger(ctx)
+		if !tw.timeout(status, errorResponse{Error: message, RequestID: requestID}) {
+			logger.Info("Request timed out after response was started")
+			// Connection is aborted, so that client does not take
+			// truncated response as complete
+			panic(http.ErrAbortHandler)
+		}
+		logger.Info("Request timed out")
+	}
+}
+
+// timeoutWriter passes writes to response writer until request times out.
+// Handler has its own header map, so that it can be used safely after
+// timeout.
+type timeoutWriter struct {
+	w           http.ResponseWriter
+	h           http.Header
+	mux         sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+// Flush flushes response, if request has not timed out
+func (tw *timeoutWriter) Flush() {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		if !tw.wroteHeader {
+			tw.writeHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// writeHeader copies headers of handler to response and writes status.
+// Lock must be held.
+func (tw *timeoutWriter) writeHeader(code int) {
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+	tw.wroteHeader = true
+}
+
+// timeout writes timeout response and discards later writes. False is
+// returned, if handler had already started response.
+func (tw *timeoutWriter) timeout(status int, body errorResponse) bool {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	tw.timedOut = true
+	if tw.wroteHeader {
+		return false
+	}
+
+	writeError(tw.w, status, body)
+	return true
+}
+
+// discard discards later writes of handler
+func (tw *timeoutWriter) discard() {
+	tw.mux.Lock()
+	tw.timedOut = true
+	tw.mux.Unlock()
+}
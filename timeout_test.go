@@ -0,0 +1,291 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astota/go-logging"
+	"github.com/labstack/echo/v4"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestEnforcedTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		started bool
+		slow    bool
+		expect  int
+		body    bool
+	}{
+		{"fast handler", 0, false, false, http.StatusOK, false},
+		{"default status", 0, false, true, http.StatusGatewayTimeout, true},
+		{"configured status", http.StatusServiceUnavailable, false, true, http.StatusServiceUnavailable, true},
+		{"response started", 0, true, true, http.StatusAccepted, false},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			teardownTest := setupTest(t)
+			defer teardownTest(t)
+
+			conf := NewConfiguration()
+			conf.MaximumRequestDuration = 50 * time.Millisecond
+			conf.Timeout.Enforce = true
+			if tst.status != 0 {
+				conf.Timeout.Status = tst.status
+			}
+			conf.RequestIDResponseHeader = "X-Request-Id"
+
+			release := make(chan struct{})
+			lateWrite := make(chan error, 1)
+			h := NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Handler", "true")
+				if tst.started {
+					w.WriteHeader(http.StatusAccepted)
+				}
+				if tst.slow {
+					<-release
+				}
+				_, err := w.Write([]byte("late"))
+				lateWrite <- err
+			}))
+
+			resp := httptest.NewRecorder()
+			func() {
+				defer func() {
+					p := recover()
+					if tst.started && tst.slow && p != http.ErrAbortHandler {
+						t.Errorf("started response is not aborted: %v", p)
+					}
+				}()
+				h.ServeHTTP(resp, createRequest(http.MethodGet))
+			}()
+			close(release)
+			writeErr := <-lateWrite
+
+			if resp.Code != tst.expect {
+				t.Fatalf("incorrect status, expected: %d, got: %d", tst.expect, resp.Code)
+			}
+			if tst.slow && writeErr != http.ErrHandlerTimeout {
+				t.Errorf("late write is not discarded: %v", writeErr)
+			}
+			if !tst.body {
+				if resp.Header().Get("X-Handler") != "true" {
+					t.Errorf("handler headers are not written")
+				}
+				return
+			}
+
+			var body errorResponse
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid timeout response: %s", resp.Body.String())
+			}
+			if body.Error != "request timeout" || body.RequestID != resp.Header().Get("X-Request-Id") || body.RequestID == "" {
+				t.Errorf("incorrect timeout response: %+v", body)
+			}
+			if resp.Header().Get("X-Handler") != "" {
+				t.Errorf("handler headers are written to timeout response")
+			}
+		})
+	}
+}
+
+func TestEnforcedTimeoutPanic(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	conf := NewConfiguration()
+	conf.Timeout.Enforce = true
+	h := NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	}))
+
+	defer func() {
+		if p := recover(); p != "test panic" {
+			t.Errorf("panic is not passed to caller: %v", p)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), createRequest(http.MethodGet))
+}
+
+func TestEnforcedTimeoutAbortStarted(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	conf := NewConfiguration()
+	conf.MaximumRequestDuration = 50 * time.Millisecond
+	conf.Timeout.Enforce = true
+
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(NewServer(conf).InitRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("part1,"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("part2"))
+	})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil {
+		t.Errorf("truncated response is read without error: '%s'", body)
+	}
+}
+
+func TestEnforcedTimeoutLatePanic(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	release := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		panic("late panic")
+	})
+
+	// Panic is logged by handler goroutine, so it has its own logger
+	logger := &panicLogger{logged: make(chan panicLog, 1)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ctx = context.WithValue(ctx, fcKey, newStore(DefaultContext{}))
+	ctx = logging.SetLogger(ctx, logger)
+	req := createRequest(http.MethodGet).WithContext(ctx)
+
+	resp := httptest.NewRecorder()
+	NewServer(NewConfiguration()).serveWithTimeout(h, resp, req, "late-panic", nil)
+	close(release)
+
+	if resp.Code != http.StatusGatewayTimeout {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusGatewayTimeout, resp.Code)
+	}
+	select {
+	case l := <-logger.logged:
+		if !strings.Contains(l.message, "late panic") {
+			t.Errorf("panic is not logged: %s", l.message)
+		}
+		if st, _ := l.fields["stacktrace"].(string); !strings.Contains(st, "goroutine") {
+			t.Errorf("stack trace is not logged: %v", l.fields)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("panic after timeout is not logged")
+	}
+}
+
+func TestEnforcedTimeoutFlagged(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	conf := NewConfiguration()
+	conf.MaximumRequestDuration = 50 * time.Millisecond
+	conf.Timeout.Enforce = true
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	// Access log is written by handler goroutine concurrently with
+	// timeout log, so it has its own logger.
+	logger := &fieldLogger{fields: logging.Fields{}}
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			defer close(done)
+			req := c.Request()
+			c.SetRequest(req.WithContext(logging.SetLogger(req.Context(), logger)))
+			return next(c)
+		}
+	})
+	e.Use(RequestTracer(), RequestLogger)
+	e.GET("/test", func(c echo.Context) error {
+		<-release
+		return c.String(http.StatusOK, "late")
+	})
+
+	resp := httptest.NewRecorder()
+	NewServer(conf).InitRequest(e).ServeHTTP(resp, createRequest(http.MethodGet))
+	close(release)
+	<-done
+
+	if resp.Code != http.StatusGatewayTimeout {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusGatewayTimeout, resp.Code)
+	}
+
+	if logger.fields["timeout"] != true || logger.fields["status"] != http.StatusGatewayTimeout {
+		t.Errorf("timeout is not flagged in access log: %v", logger.fields)
+	}
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("incorrect number of spans: %d", len(spans))
+	}
+	if spans[0].Tag("timeout") != true || spans[0].Tag("error") != true {
+		t.Errorf("timeout is not flagged on span: %v", spans[0].Tags())
+	}
+	if status := spans[0].Tag("http.status_code"); status != uint16(http.StatusGatewayTimeout) {
+		t.Errorf("incorrect status on span: %v", status)
+	}
+}
+
+// fieldLogger collects fields of request logger. Only methods used by
+// RequestLogger are implemented.
+type fieldLogger struct {
+	logging.Logger
+	fields logging.Fields
+}
+
+func (l *fieldLogger) AddFields(f logging.Fields) logging.Logger {
+	for k, v := range f {
+		l.fields[k] = v
+	}
+	return l
+}
+
+func (l *fieldLogger) Info(string) {}
+
+// panicLog is error logged by panicLogger
+type panicLog struct {
+	fields  logging.Fields
+	message string
+}
+
+// panicLogger sends logged errors to channel. Only methods used by
+// serveWithTimeout are implemented.
+type panicLogger struct {
+	logging.Logger
+	fields logging.Fields
+	logged chan panicLog
+}
+
+func (l *panicLogger) AddFields(f logging.Fields) logging.Logger {
+	fields := logging.Fields{}
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	for k, v := range f {
+		fields[k] = v
+	}
+	return &panicLogger{fields: fields, logged: l.logged}
+}
+
+func (l *panicLogger) Info(string) {}
+
+func (l *panicLogger) Errorf(f string, i ...interface{}) {
+	l.logged <- panicLog{fields: l.fields, message: fmt.Sprintf(f, i...)}
+}
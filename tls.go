@@ -0,0 +1,249 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/astota/go-logging"
+)
+
+// TLSConfiguration contains TLS settings of server
+type TLSConfiguration struct {
+	// Certificate file in PEM format
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	// Private key file in PEM format
+	KeyFile string `yaml:"key_file" json:"key_file"`
+	// Minimum TLS version: 1.0, 1.1, 1.2 or 1.3. Default: 1.2
+	MinVersion string `yaml:"min_version" json:"min_version"`
+	// Allowed cipher suites, example TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+	// If not defined, Go defaults are used. TLS 1.3 suites are not
+	// configurable.
+	CipherSuites []string `yaml:"cipher_suites" json:"cipher_suites"`
+	// Protocols offered in ALPN negotiation. If not defined, h2 and
+	// http/1.1 are used.
+	NextProtos []string `yaml:"next_protos" json:"next_protos"`
+	// Client certificate verification: none, optional or require.
+	// Default: none
+	ClientAuth string `yaml:"client_auth" json:"client_auth"`
+	// CA bundle file in PEM format, which is used to verify client
+	// certificates.
+	ClientCAFile string `yaml:"client_ca_file" json:"client_ca_file"`
+	// Interval how often certificate files are checked for changes.
+	// Default: 1m
+	ReloadInterval time.Duration `yaml:"reload_interval" json:"reload_interval"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var cipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":          tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":        tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+// newTLSConfig creates TLS configuration. Certificate is loaded using
+// given reloader. Settings of base configuration are preserved if base
+// is defined.
+func (c TLSConfiguration) newTLSConfig(base *tls.Config, r *certificateReloader) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	cfg.GetCertificate = r.GetCertificate
+
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS version '%s'", c.MinVersion)
+		}
+		cfg.MinVersion = v
+	}
+
+	if len(c.CipherSuites) > 0 {
+		cfg.CipherSuites = make([]uint16, 0, len(c.CipherSuites))
+		for _, name := range c.CipherSuites {
+			id, ok := cipherSuites[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown cipher suite '%s'", name)
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, id)
+		}
+	}
+
+	if len(c.NextProtos) > 0 {
+		cfg.NextProtos = c.NextProtos
+	}
+
+	if err := c.configureClientAuth(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// certificateReloader loads certificate and reloads it when files change
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mux     sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// newCertificateReloader creates reloader and loads certificate
+func newCertificateReloader(certFile, keyFile string) (*certificateReloader, error) {
+	r := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns current certificate. It is used as
+// tls.Config.GetCertificate callback.
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.cert, nil
+}
+
+// lastModified returns latest modification time of certificate files
+func (r *certificateReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, fn := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(fn)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// load reads certificate files and replaces current certificate
+func (r *certificateReloader) load() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return fmt.Errorf("cannot read certificate: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load certificate: %w", err)
+	}
+
+	r.mux.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mux.Unlock()
+	return nil
+}
+
+// reloadIfChanged reloads certificate, if files are changed after
+// previous load. Previous certificate is kept, if reload fails.
+func (r *certificateReloader) reloadIfChanged() {
+	logger := logging.NewLogger().AddFields(logging.Fields{
+		"cert_file": r.certFile,
+	})
+
+	modTime, err := r.lastModified()
+	if err != nil {
+		logger.WithError(err).Error("Certificate reload failed")
+		return
+	}
+
+	r.mux.RLock()
+	changed := !modTime.Equal(r.modTime)
+	r.mux.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		logger.WithError(err).Error("Certificate reload failed")
+		return
+	}
+	logger.Info("Certificate reloaded")
+}
+
+// watch checks certificate files periodically until context is done
+func (r *certificateReloader) watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			r.reloadIfChanged()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// configureTLS sets TLS configuration of server from Configuration, if
+// certificate is configured. Certificate files are watched until context
+// is done. Without certificate, client authentication is applied to TLS
+// configuration of server.
+func (s *Server) configureTLS(ctx context.Context) error {
+	c := s.conf.TLS
+	if c.CertFile == "" {
+		if c.ClientAuth == "" || c.ClientAuth == ClientAuthNone {
+			return nil
+		}
+		if s.server.TLSConfig == nil {
+			return fmt.Errorf("client auth '%s' requires certificate or TLS config of server", c.ClientAuth)
+		}
+		cfg := s.server.TLSConfig.Clone()
+		if err := c.configureClientAuth(cfg); err != nil {
+			return err
+		}
+		s.server.TLSConfig = cfg
+		return nil
+	}
+
+	r, err := newCertificateReloader(c.CertFile, c.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := c.newTLSConfig(s.server.TLSConfig, r)
+	if err != nil {
+		return err
+	}
+	s.server.TLSConfig = cfg
+
+	go r.watch(ctx, c.ReloadInterval)
+	return nil
+}
@@ -0,0 +1,196 @@
+package rest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// writeTestCertificate writes self-signed certificate and key files
+func writeTestCertificate(t *testing.T, dir, cn string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %s", err.Error())
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{"localhost"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %s", err.Error())
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("cannot marshal key: %s", err.Error())
+	}
+
+	certFile := filepath.Join(dir, cn+".crt")
+	keyFile := filepath.Join(dir, cn+".key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return certFile, keyFile
+}
+
+func certificateCommonName(t *testing.T, r *certificateReloader) string {
+	t.Helper()
+	cert, _ := r.GetCertificate(nil)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %s", err.Error())
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateReload(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	dir, err := ioutil.TempDir("", "rest-tls")
+	if err != nil {
+		t.Fatalf("cannot create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCertificate(t, dir, "first")
+	r, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("cannot load certificate: %s", err.Error())
+	}
+	if cn := certificateCommonName(t, r); cn != "first" {
+		t.Errorf("incorrect certificate, expected: '%s', got: '%s'", "first", cn)
+	}
+
+	// Invalid certificate is not loaded
+	ioutil.WriteFile(certFile, []byte("invalid"), 0600)
+	os.Chtimes(certFile, time.Now(), time.Now().Add(time.Minute))
+	r.reloadIfChanged()
+	if cn := certificateCommonName(t, r); cn != "first" {
+		t.Errorf("invalid certificate is loaded")
+	}
+
+	// Valid certificate is reloaded
+	secondCert, secondKey := writeTestCertificate(t, dir, "second")
+	os.Rename(secondCert, certFile)
+	os.Rename(secondKey, keyFile)
+	os.Chtimes(certFile, time.Now(), time.Now().Add(2*time.Minute))
+	r.reloadIfChanged()
+	if cn := certificateCommonName(t, r); cn != "second" {
+		t.Errorf("certificate is not reloaded, expected: '%s', got: '%s'", "second", cn)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    TLSConfiguration
+		wantErr bool
+	}{
+		{"defaults", TLSConfiguration{}, false},
+		{"all settings", TLSConfiguration{MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, NextProtos: []string{"http/1.1"}}, false},
+		{"invalid version", TLSConfiguration{MinVersion: "2.0"}, true},
+		{"invalid cipher suite", TLSConfiguration{CipherSuites: []string{"invalid"}}, true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			base := &tls.Config{ServerName: "base"}
+			cfg, err := tst.conf.newTLSConfig(base, &certificateReloader{})
+			if (err != nil) != tst.wantErr {
+				t.Fatalf("unexpected error value: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if cfg.ServerName != "base" {
+				t.Errorf("base configuration is not used")
+			}
+			if cfg.GetCertificate == nil {
+				t.Errorf("GetCertificate is not set")
+			}
+			if v := tlsVersions[tst.conf.MinVersion]; cfg.MinVersion != v {
+				t.Errorf("incorrect min version, expected: %d, got: %d", v, cfg.MinVersion)
+			}
+			if len(cfg.CipherSuites) != len(tst.conf.CipherSuites) {
+				t.Errorf("incorrect cipher suites: %v", cfg.CipherSuites)
+			}
+			if len(cfg.NextProtos) != len(tst.conf.NextProtos) {
+				t.Errorf("incorrect ALPN protocols: %v", cfg.NextProtos)
+			}
+		})
+	}
+}
+
+func TestServerRunTLSConfiguration(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	dir, err := ioutil.TempDir("", "rest-tls")
+	if err != nil {
+		t.Fatalf("cannot create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	addr := freeAddress(t)
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	conf.TLS.CertFile, conf.TLS.KeyFile = writeTestCertificate(t, dir, "localhost")
+	s := NewServer(conf, WithAddress(addr))
+	s.Echo().GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, "")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunTLSContext(ctx)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+	}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("https://" + addr + "/test"); err == nil {
+			resp.Body.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("request failed: %s", err.Error())
+	} else if resp.TLS == nil || resp.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("TLS is not used correctly")
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
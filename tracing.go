@@ -10,6 +10,7 @@ import (
 	middleware "github.com/foodiefm/opentracing/contrib/github.com/labstack/echo"
 	"github.com/labstack/echo/v4"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 )
 
 const (
@@ -22,11 +23,37 @@ func RequestTracer() echo.MiddlewareFunc {
 		if fctx, err := GetDefaultContext(ctx); err == nil {
 			span.SetTag("http.BMG-Organization-Id", fctx.OrganizationID)
 			span.SetTag("http.BMG-Request-Id", fctx.RequestID)
+			if fctx.ClientIdentity.CommonName != "" {
+				span.SetTag("tls.client_cn", fctx.ClientIdentity.CommonName)
+			}
+			if fctx.ClientIdentity.SPIFFEID != "" {
+				span.SetTag("tls.client_spiffe_id", fctx.ClientIdentity.SPIFFEID)
+			}
+			for tag, value := range fctx.tags {
+				span.SetTag(tag, value)
+			}
 		}
 
 		return ctx
 	}
-	return middleware.RequestTracer(inject)
+	tracer := middleware.RequestTracer(inject)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return tracer(func(c echo.Context) error {
+			err := next(c)
+
+			// Span is finished after this with status of response, so
+			// status of timeout response is set to it.
+			if status, ok := getStore(c.Request().Context()).timedOut(); ok {
+				c.Response().Status = status
+				if span := opentracing.SpanFromContext(c.Request().Context()); span != nil {
+					span.SetTag("timeout", true)
+					ext.Error.Set(span, true)
+				}
+			}
+			return err
+		})
+	}
 }
 
 // InitGlobalTracer initialises global OpenTracing tracer.
@@ -209,3 +209,30 @@ func TestTracerConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestTracerClientIdentity(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+
+	req := initTracerTestRequest(t)
+	req = req.WithContext(setDefaultContext(req.Context(), DefaultContext{
+		ClientIdentity: ClientIdentity{CommonName: "client", SPIFFEID: "spiffe://example.org/client"},
+	}))
+	callTracerTestRequest(t, req, func(c echo.Context) error {
+		return c.String(http.StatusOK, "")
+	})
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("incorrect number of spans: %d", len(spans))
+	}
+	if cn := spans[0].Tag("tls.client_cn"); cn != "client" {
+		t.Errorf("incorrect tls.client_cn tag: %v", cn)
+	}
+	if id := spans[0].Tag("tls.client_spiffe_id"); id != "spiffe://example.org/client" {
+		t.Errorf("incorrect tls.client_spiffe_id tag: %v", id)
+	}
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/sebest/xff"
+)
+
+// unixScheme is prefix of Unix domain socket addresses, example
+// unix:///var/run/app.sock
+const unixScheme = "unix://"
+
+// listenUnix opens Unix domain socket listener. Stale socket file of
+// previous process is removed and file permissions are set, when mode
+// is defined.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("cannot change socket permissions: %w", err)
+		}
+	}
+
+	return ln, nil
+}
+
+// removeStaleSocket removes socket file, if no process is listening it
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("file '%s' exists and is not socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket '%s' is in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+// isUnixRequest tells if request is received through Unix domain socket
+func isUnixRequest(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// userIP returns IP address of user. Unix domain socket peers do not have
+// address, so only X-Forwarded-For header is used for those requests.
+func userIP(r *http.Request) string {
+	if isUnixRequest(r) {
+		return xff.Parse(r.Header.Get("X-Forwarded-For"))
+	}
+
+	if sip, _, err := net.SplitHostPort(xff.GetRemoteAddr(r)); err == nil {
+		return sip
+	}
+	return ""
+}
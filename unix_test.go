@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestServerUnixSocket(t *testing.T) {
+	teardownTest := setupTest(t)
+	defer teardownTest(t)
+
+	dir, err := ioutil.TempDir("", "rest-unix")
+	if err != nil {
+		t.Fatalf("cannot create directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.sock")
+
+	// Leave stale socket file, which is removed on start
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	conf := NewConfiguration()
+	conf.ShutdownGraceTime = time.Second
+	conf.UnixSocketMode = 0600
+	s := NewServer(conf, WithAddress(unixScheme+path))
+	s.Echo().GET("/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, "")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.RunContext(ctx)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+			DisableKeepAlives: true,
+		},
+	}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("http://unix/test"); err == nil {
+			resp.Body.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("request failed: %s", err.Error())
+	} else if resp.StatusCode != http.StatusOK {
+		t.Errorf("incorrect status, expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if info, err := os.Stat(path); err != nil {
+		t.Errorf("socket file missing: %s", err.Error())
+	} else if info.Mode().Perm() != conf.UnixSocketMode {
+		t.Errorf("incorrect socket permissions, expected: %s, got: %s", conf.UnixSocketMode, info.Mode().Perm())
+	}
+
+	// Socket in use cannot be removed
+	other := NewServer(conf, WithAddress(unixScheme+path))
+	if err := other.RunContext(context.Background()); err == nil {
+		t.Errorf("error is not returned when socket is in use")
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestUserIP(t *testing.T) {
+	unixAddr := &net.UnixAddr{Name: "/tmp/app.sock", Net: "unix"}
+	tcpAddr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}
+
+	tests := []struct {
+		name       string
+		localAddr  net.Addr
+		remoteAddr string
+		xff        string
+		expected   string
+	}{
+		{"tcp connection", tcpAddr, "10.10.10.10:10000", "", "10.10.10.10"},
+		{"tcp connection with X-Forwarded-For", tcpAddr, "10.10.10.10:10000", "123.123.123.123", "123.123.123.123"},
+		{"unix connection", unixAddr, "@", "", ""},
+		{"unix connection with X-Forwarded-For", unixAddr, "@", "123.123.123.123", "123.123.123.123"},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			req := createRequest(http.MethodGet)
+			req.RemoteAddr = tst.remoteAddr
+			if tst.xff != "" {
+				req.Header.Set("X-Forwarded-For", tst.xff)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, tst.localAddr))
+
+			if ip := userIP(req); ip != tst.expected {
+				t.Errorf("incorrect user ip, expected: '%s', got: '%s'", tst.expected, ip)
+			}
+		})
+	}
+}